@@ -23,7 +23,16 @@
 // All errors additionally contain their package, filename and line
 // number. These information can be retrieved using Location(). In
 // case of a chain of annotated errors those can be retrieved as a
-// slice of errors with Stack().
+// slice of errors with Stack(). After switching it on with
+// SetStackTrace() also the stack trace of the creation is captured,
+// it can be retrieved with StackTrace().
+//
+// The errors also work with errors.Is() and errors.As() of the standard
+// library. Annotated errors are unwrapped and errors of this package
+// are equal if they have the same code.
+//
+// Retry() calls a function until it succeeds, returns an error not
+// classified as retryable, or the number of attempts is reached.
 package errors
 
 // EOF
@@ -14,8 +14,11 @@ package errors
 import (
 	"fmt"
 	"path"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 //--------------------
@@ -30,7 +33,7 @@ type Messages map[int]string
 func (m Messages) Format(code int, args ...interface{}) string {
 	if m == nil || m[code] == "" {
 		if len(args) == 0 {
-			return fmt.Sprintf("[ERRORS:999] invalid error code '%d'")
+			return fmt.Sprintf("[ERRORS:999] invalid error code '%d'", code)
 		}
 		format := fmt.Sprintf("%v", args[0])
 		return fmt.Sprintf(format, args[1:]...)
@@ -59,22 +62,42 @@ var errorMessages = Messages{
 // ERROR
 //--------------------
 
+// stackTracing signals if stack traces are captured.
+var stackTracing int32
+
+// SetStackTrace switches the capturing of stack traces when creating
+// errors with New() or Annotate() on or off. Default is off.
+func SetStackTrace(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&stackTracing, v)
+}
+
 // errorBox encapsulates an error.
 type errorBox struct {
-	err  error
-	code int
-	msg  string
-	info *callInfo
+	err   error
+	code  int
+	msgs  Messages
+	msg   string
+	info  *callInfo
+	stack []uintptr
 }
 
 // newErrorBox creates an initialized error box.
 func newErrorBox(err error, code int, msgs Messages, args ...interface{}) *errorBox {
-	return &errorBox{
+	eb := &errorBox{
 		err:  err,
 		code: code,
+		msgs: msgs,
 		msg:  msgs.Format(code, args...),
 		info: retrieveCallInfo(),
 	}
+	if atomic.LoadInt32(&stackTracing) == 1 {
+		eb.stack = retrieveStack()
+	}
+	return eb
 }
 
 // Error implements the error interface.
@@ -85,20 +108,70 @@ func (eb *errorBox) Error() string {
 	return fmt.Sprintf("[%s:%03d] %s", eb.info.packagePart, eb.code, eb.msg)
 }
 
-// errorCollection bundles multiple errors.
-type errorCollection struct {
+// Unwrap returns the annotated error, so errorBox works with
+// errors.Is() and errors.As() of the standard library.
+func (eb *errorBox) Unwrap() error {
+	return eb.err
+}
+
+// Is checks if the target has been created by this package
+// with the same messages and code. As codes are defined per
+// package the messages tell which package created the error.
+func (eb *errorBox) Is(target error) bool {
+	if t, ok := target.(*errorBox); ok {
+		return t.code == eb.code && sameMessages(t.msgs, eb.msgs)
+	}
+	return false
+}
+
+// sameMessages checks if both messages are the same instance.
+func sameMessages(a, b Messages) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// Collection bundles multiple errors. The zero value is
+// an empty collection ready to use.
+type Collection struct {
 	errs []error
 }
 
-// Error implements the error interface.
-func (ec *errorCollection) Error() string {
-	errMsgs := make([]string, len(ec.errs))
-	for i, err := range ec.errs {
-		errMsgs[i] = err.Error()
+// Append adds an error to the collection, nil is ignored.
+func (c *Collection) Append(err error) {
+	if err != nil {
+		c.errs = append(c.errs, err)
+	}
+}
+
+// HasErrors returns true if the collection contains errors.
+func (c *Collection) HasErrors() bool {
+	return c != nil && len(c.errs) > 0
+}
+
+// ToError returns nil for a nil or empty collection,
+// otherwise the collection itself.
+func (c *Collection) ToError() error {
+	if !c.HasErrors() {
+		return nil
+	}
+	return c
+}
+
+// Error implements the error interface. The messages are
+// prefixed by their index and separated by newlines.
+func (c *Collection) Error() string {
+	errMsgs := make([]string, len(c.errs))
+	for i, err := range c.errs {
+		errMsgs[i] = fmt.Sprintf("%d: %v", i, err)
 	}
 	return strings.Join(errMsgs, "\n")
 }
 
+// Unwrap returns the collected errors, so errors.Is() and
+// errors.As() of the standard library check each of them.
+func (c *Collection) Unwrap() []error {
+	return c.errs
+}
+
 // Annotate creates an error wrapping another one together with a
 // a code.
 func Annotate(err error, code int, msgs Messages, args ...interface{}) error {
@@ -110,9 +183,11 @@ func New(code int, msgs Messages, args ...interface{}) error {
 	return newErrorBox(nil, code, msgs, args...)
 }
 
-// Collect collects multiple errors into one.
+// Collect collects multiple errors into one. Its message
+// contains the messages of the errors prefixed by their
+// index, like "0: first\n1: second".
 func Collect(errs ...error) error {
-	return &errorCollection{
+	return &Collection{
 		errs: errs,
 	}
 }
@@ -124,11 +199,28 @@ func Valid(err error) bool {
 	return ok
 }
 
-// IsError checks if an error is one created by this
-// package and has the passed code
+// IsError checks if an error is one created by this package
+// and has the passed code. It also checks the annotated errors,
+// errors wrapped by others, like with fmt.Errorf() and %w, and
+// collected errors, so it's true if any of them has the code.
 func IsError(err error, code int) bool {
-	if e, ok := err.(*errorBox); ok {
-		return e.code == code
+	for err != nil {
+		if e, ok := err.(*errorBox); ok && e.code == code {
+			return true
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, uerr := range u.Unwrap() {
+				if IsError(uerr, code) {
+					return true
+				}
+			}
+			return false
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return false
+		}
 	}
 	return false
 }
@@ -160,9 +252,59 @@ func Stack(err error) []error {
 	return []error{err}
 }
 
+// StackTrace returns the stack trace captured when the error has
+// been created, one frame per entry formatted as function followed
+// by file and line. In case of annotated errors the trace of the
+// innermost error with a captured trace is returned, so it's the
+// trace of the original error. Like IsError it also checks errors
+// wrapped by others and collected errors, here the first one with
+// a trace is taken. Without trace nil is returned.
+func StackTrace(err error) []string {
+	stack := capturedStack(err)
+	if stack == nil {
+		return nil
+	}
+	trace := []string{}
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		trace = append(trace, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return trace
+}
+
+// capturedStack returns the innermost captured stack of the error
+// chain. In case of multiple wrapped errors the first one with a
+// stack is taken.
+func capturedStack(err error) []uintptr {
+	var stack []uintptr
+	for err != nil {
+		if eb, ok := err.(*errorBox); ok && eb.stack != nil {
+			stack = eb.stack
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, uerr := range u.Unwrap() {
+				if ustack := capturedStack(uerr); ustack != nil {
+					return ustack
+				}
+			}
+			return stack
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return stack
+		}
+	}
+	return stack
+}
+
 // All returns a slice of errors in case of collected errors.
 func All(err error) []error {
-	if ec, ok := err.(*errorCollection); ok {
+	if ec, ok := err.(*Collection); ok {
 		all := make([]error, len(ec.errs))
 		copy(all, ec.errs)
 		return all
@@ -178,7 +320,7 @@ func DoAll(err error, f func(error)) {
 		for _, serr := range Stack(err) {
 			f(serr)
 		}
-	case *errorCollection:
+	case *Collection:
 		for _, aerr := range All(err) {
 			f(aerr)
 		}
@@ -217,6 +359,31 @@ func IsDeprecatedError(err error) bool {
 	return IsError(err, ErrDeprecated)
 }
 
+//--------------------
+// RETRY
+//--------------------
+
+// Retry calls fn until it returns nil, an error not classified as
+// retryable, or the number of attempts is reached. In the last two
+// cases the last error is returned. Before attempt n+1 it waits the
+// duration backoff returns for n, starting with 1. Without backoff
+// the attempts follow immediately, without retryable all errors
+// are retried. At least one attempt is made.
+func Retry(attempts int, backoff func(n int) time.Duration, retryable func(error) bool, fn func() error) error {
+	for n := 1; ; n++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if n >= attempts || (retryable != nil && !retryable(err)) {
+			return err
+		}
+		if backoff != nil {
+			time.Sleep(backoff(n))
+		}
+	}
+}
+
 //--------------------
 // PRIVATE HELPERS
 //--------------------
@@ -259,4 +426,12 @@ func retrieveCallInfo() *callInfo {
 	}
 }
 
+// retrieveStack returns the program counters of the
+// caller of New() or Annotate() and above.
+func retrieveStack() []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(4, pcs)
+	return pcs[:n]
+}
+
 // EOF
@@ -12,7 +12,11 @@ package errors_test
 //--------------------
 
 import (
+	stderrors "errors"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/tideland/golib/audit"
 	"github.com/tideland/golib/errors"
@@ -39,6 +43,19 @@ func TestIsError(t *testing.T) {
 	assert.ErrorMatch(err, "test error 2")
 	assert.False(errors.IsError(err, ec))
 	assert.False(errors.IsError(err, 0))
+
+	// Codes of annotated errors are found at any depth.
+	messages = errors.Messages{1: "foo", 2: "bar", 3: "baz", 4: "yadda"}
+	err = errors.Annotate(testError("xxx"), 1, messages)
+	err = errors.Annotate(err, 2, messages)
+	err = fmt.Errorf("wrapped: %w", err)
+	err = errors.Annotate(err, 3, messages)
+
+	assert.True(errors.IsError(err, 1))
+	assert.True(errors.IsError(err, 2))
+	assert.True(errors.IsError(err, 3))
+	assert.False(errors.IsError(err, 4))
+	assert.False(errors.IsError(nil, 1))
 }
 
 // TestValidation checks the validation of errors and
@@ -55,7 +72,7 @@ func TestValidation(t *testing.T) {
 	assert.Nil(lerr)
 	assert.Equal(packageName, "github.com/tideland/golib/errors_test")
 	assert.Equal(fileName, "errors_test.go")
-	assert.Equal(line, 51)
+	assert.Equal(line, 68)
 }
 
 // TestAnnotation the annotation of errors with new errors.
@@ -73,6 +90,90 @@ func TestAnnotation(t *testing.T) {
 	assert.Length(errors.Stack(err), 2)
 }
 
+// TestStandardIsAs tests the usage with errors.Is() and
+// errors.As() of the standard library.
+func TestStandardIsAs(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	messages := errors.Messages{
+		1: "foo",
+		2: "bar",
+		3: "baz",
+	}
+	_, perr := os.Open("/some/not/existing/file")
+	errA := errors.Annotate(perr, 1, messages)
+	errB := errors.Annotate(errA, 2, messages)
+	sentinel := errors.New(1, messages)
+
+	assert.Equal(stderrors.Unwrap(errB), errA)
+	assert.Equal(stderrors.Unwrap(errA), perr)
+	assert.True(stderrors.Is(errB, sentinel))
+	assert.True(stderrors.Is(errB, errors.New(2, messages)))
+	assert.False(stderrors.Is(errB, errors.New(3, messages)))
+	assert.True(stderrors.Is(errB, os.ErrNotExist))
+	assert.False(stderrors.Is(stderrors.New("foo"), sentinel))
+
+	var pathErr *os.PathError
+	assert.True(stderrors.As(errB, &pathErr))
+	assert.Equal(pathErr.Path, "/some/not/existing/file")
+
+	// Errors created with fmt.Errorf() and %w.
+	werr := fmt.Errorf("wrapped: %w", errB)
+	assert.True(stderrors.Is(werr, sentinel))
+
+	// Same codes of different packages.
+	otherMessages := errors.Messages{
+		1: "foo",
+	}
+	assert.False(stderrors.Is(errB, errors.New(1, otherMessages)))
+	assert.False(stderrors.Is(errors.New(1, otherMessages), sentinel))
+	invalid := errors.Annotated(perr)
+	assert.True(errors.IsError(invalid, errors.ErrInvalidErrorType))
+	assert.False(stderrors.Is(invalid, sentinel))
+	assert.True(stderrors.Is(invalid, errors.Annotated(perr)))
+}
+
+// TestStackTrace tests the capturing of stack traces.
+func TestStackTrace(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	messages := errors.Messages{
+		1: "foo",
+		2: "bar",
+	}
+
+	// Default is without stack traces.
+	err := errors.New(1, messages)
+	assert.Nil(errors.StackTrace(err))
+	assert.Nil(errors.StackTrace(testError("foo")))
+
+	errors.SetStackTrace(true)
+	defer errors.SetStackTrace(false)
+
+	errA := failingFunc(messages)
+	errB := errors.Annotate(errA, 2, messages)
+	trace := errors.StackTrace(errB)
+	assert.True(len(trace) > 1)
+	assert.Match(trace[0], `^github.com/tideland/golib/errors_test.failingFunc .*/errors_test.go:[0-9]+$`)
+	assert.Match(trace[1], `^github.com/tideland/golib/errors_test.TestStackTrace .*/errors_test.go:[0-9]+$`)
+	assert.Equal(errors.StackTrace(errA), trace)
+
+	// Annotating errors without trace captures a new one.
+	errors.SetStackTrace(false)
+	errA = errors.New(1, messages)
+	errors.SetStackTrace(true)
+	errB = errors.Annotate(errA, 2, messages)
+	trace = errors.StackTrace(errB)
+	assert.Match(trace[0], `^github.com/tideland/golib/errors_test.TestStackTrace .*`)
+
+	// Wrapped and collected errors.
+	errA = failingFunc(messages)
+	trace = errors.StackTrace(errA)
+	errW := fmt.Errorf("wrapped: %w", errA)
+	assert.Equal(errors.StackTrace(errW), trace)
+	assert.Equal(errors.StackTrace(errors.Collect(testError("foo"), errW)), trace)
+	assert.Nil(errors.StackTrace(errors.Collect(testError("foo"), testError("bar"))))
+}
+
 // TestCollection tests the collection of multiple errors to one.
 func TestCollection(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -83,7 +184,87 @@ func TestCollection(t *testing.T) {
 	errD := testError("yadda")
 	cerr := errors.Collect(errA, errB, errC, errD)
 
-	assert.ErrorMatch(cerr, "foo\nbar\nbaz\nyadda")
+	assert.ErrorMatch(cerr, "0: foo\n1: bar\n2: baz\n3: yadda")
+
+	// Appending errors.
+	var c *errors.Collection
+	assert.False(c.HasErrors())
+	assert.Nil(c.ToError())
+	c = &errors.Collection{}
+	assert.Nil(c.ToError())
+	c.Append(nil)
+	assert.False(c.HasErrors())
+	_, perr := os.Open("/some/not/existing/file")
+	messages := errors.Messages{1: "foo", 2: "bar"}
+	c.Append(errors.New(1, messages))
+	c.Append(perr)
+	assert.True(c.HasErrors())
+	err := c.ToError()
+	assert.ErrorMatch(err, `0: \[ERRORS_TEST:001\] foo\n1: open /some/not/existing/file: .*`)
+	assert.Length(errors.All(err), 2)
+
+	// Checking with the standard library.
+	assert.True(stderrors.Is(err, errors.New(1, messages)))
+	assert.False(stderrors.Is(err, errors.New(2, messages)))
+	assert.True(stderrors.Is(err, os.ErrNotExist))
+	var pathErr *os.PathError
+	assert.True(stderrors.As(err, &pathErr))
+
+	// Checking the codes of collected and annotated errors.
+	assert.True(errors.IsError(err, 1))
+	assert.False(errors.IsError(err, 2))
+	aerr := errors.Annotate(err, 2, messages)
+	assert.True(errors.IsError(aerr, 1))
+	assert.True(errors.IsError(aerr, 2))
+	assert.False(errors.IsError(aerr, 3))
+}
+
+// TestRetry tests the retrying of failing functions.
+func TestRetry(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	messages := errors.Messages{1: "temporary", 2: "permanent"}
+	var waits []time.Duration
+	backoff := func(n int) time.Duration {
+		d := time.Duration(n) * time.Millisecond
+		waits = append(waits, d)
+		return d
+	}
+	retryable := func(err error) bool {
+		return errors.IsError(err, 1)
+	}
+	failing := func(failures int, code int) func() error {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= failures {
+				return errors.New(code, messages)
+			}
+			return nil
+		}
+	}
+
+	// Failing twice, then succeeding.
+	err := errors.Retry(5, backoff, retryable, failing(2, 1))
+	assert.Nil(err)
+	assert.Equal(waits, []time.Duration{time.Millisecond, 2 * time.Millisecond})
+
+	// Running out of attempts.
+	waits = nil
+	err = errors.Retry(3, backoff, retryable, failing(5, 1))
+	assert.ErrorMatch(err, `.* temporary`)
+	assert.Length(waits, 2)
+
+	// Not retryable errors are returned immediately.
+	waits = nil
+	err = errors.Retry(3, backoff, retryable, failing(5, 2))
+	assert.ErrorMatch(err, `.* permanent`)
+	assert.Length(waits, 0)
+
+	// Without backoff and classification.
+	err = errors.Retry(3, nil, nil, failing(2, 2))
+	assert.Nil(err)
+	err = errors.Retry(0, nil, nil, failing(1, 2))
+	assert.ErrorMatch(err, `.* permanent`)
 }
 
 // TestDoAll tests the iteration over errors.
@@ -138,6 +319,10 @@ func TestDoAll(t *testing.T) {
 
 type testError string
 
+func failingFunc(messages errors.Messages) error {
+	return errors.New(1, messages)
+}
+
 func (e testError) Error() string {
 	return string(e)
 }
@@ -0,0 +1,201 @@
+// Tideland Go Library - Etc - Builder
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tideland/golib/errors"
+	"github.com/tideland/golib/sml"
+)
+
+//--------------------
+// BUILDER
+//--------------------
+
+// comments maps the slash separated full paths of nodes to the
+// comments in front of them. Comments at the end of a node are
+// stored with a trailing slash.
+type comments map[string][]string
+
+// includeDirective starts comments including other files. The
+// "@" prevents ordinary comments starting with "include" from
+// being taken as directive.
+const includeDirective = "@include "
+
+// builder extends the SML key/value tree builder by
+// collecting the comments of the nodes and including
+// other files. In strict mode it also rejects keys
+// defined more than once.
+type builder struct {
+	*sml.KeyStringValueTreeBuilder
+
+	strict   bool
+	seen     map[string]bool
+	files    []string
+	path     []string
+	pending  []string
+	comments comments
+}
+
+// newBuilder creates a builder for a configuration. The
+// filename is needed to resolve includes, it is empty
+// when reading other sources.
+func newBuilder(strict bool, filename string) *builder {
+	b := &builder{
+		KeyStringValueTreeBuilder: sml.NewKeyStringValueTreeBuilder(),
+		strict:                    strict,
+		seen:                      map[string]bool{},
+		comments:                  comments{},
+	}
+	if filename != "" {
+		b.files = []string{filepath.Clean(filename)}
+	}
+	return b
+}
+
+// BeginTagNode implements the sml.Builder interface.
+func (b *builder) BeginTagNode(tag string) error {
+	if err := b.KeyStringValueTreeBuilder.BeginTagNode(tag); err != nil {
+		return err
+	}
+	b.path = append(b.path, tag)
+	key := strings.Join(b.path, "/")
+	if b.strict {
+		if b.seen[key] {
+			return errors.New(ErrDuplicateKey, errorMessages, pathToString(b.path))
+		}
+		b.seen[key] = true
+	}
+	b.flush(key)
+	return nil
+}
+
+// EndTagNode implements the sml.Builder interface.
+func (b *builder) EndTagNode() error {
+	if err := b.KeyStringValueTreeBuilder.EndTagNode(); err != nil {
+		return err
+	}
+	b.flush(strings.Join(b.path, "/") + "/")
+	b.path = b.path[:len(b.path)-1]
+	return nil
+}
+
+// CommentNode implements the sml.Builder interface.
+func (b *builder) CommentNode(comment string) error {
+	if err := b.KeyStringValueTreeBuilder.CommentNode(comment); err != nil {
+		return err
+	}
+	trimmed := strings.TrimSpace(comment)
+	if strings.HasPrefix(trimmed, includeDirective) && len(b.files) > 0 {
+		return b.include(strings.TrimSpace(trimmed[len(includeDirective):]))
+	}
+	b.pending = append(b.pending, strings.TrimSpace(comment))
+	return nil
+}
+
+// include reads the file relative to the including one and
+// adds its nodes below the root to the current node.
+func (b *builder) include(filename string) error {
+	current := b.files[len(b.files)-1]
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(current), filename)
+	}
+	filename = filepath.Clean(filename)
+	for i, file := range b.files {
+		if file == filename {
+			chain := strings.Join(append(b.files[i:], filename), " -> ")
+			return errors.New(ErrCircularInclude, errorMessages, chain)
+		}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.Annotate(err, ErrCannotInclude, errorMessages, filename, "file cannot be opened")
+	}
+	defer f.Close()
+	b.files = append(b.files, filename)
+	defer func() {
+		b.files = b.files[:len(b.files)-1]
+	}()
+	if err = sml.ReadSML(f, &includer{builder: b}); err != nil {
+		return errors.Annotate(err, ErrCannotInclude, errorMessages, filename, "illegal source format")
+	}
+	return nil
+}
+
+// includer passes the nodes of an included file below
+// its root to the builder.
+type includer struct {
+	*builder
+
+	depth int
+}
+
+// BeginTagNode implements the sml.Builder interface.
+func (i *includer) BeginTagNode(tag string) error {
+	i.depth++
+	if i.depth == 1 {
+		if tag != "etc" {
+			return errors.New(ErrCannotInclude, errorMessages, i.files[len(i.files)-1], "root is no etc node")
+		}
+		return nil
+	}
+	return i.builder.BeginTagNode(tag)
+}
+
+// EndTagNode implements the sml.Builder interface.
+func (i *includer) EndTagNode() error {
+	i.depth--
+	if i.depth == 0 {
+		return nil
+	}
+	return i.builder.EndTagNode()
+}
+
+// flush stores the pending comments for the key.
+func (b *builder) flush(key string) {
+	if len(b.pending) > 0 {
+		b.comments[key] = append(b.comments[key], b.pending...)
+		b.pending = nil
+	}
+}
+
+// move returns the comments of the paths below the prefix,
+// those will be replaced by the new prefix. The comments in
+// front of the prefix node itself are not moved, they would
+// be written outside of the new root.
+func (cs comments) move(prefix, newPrefix string) comments {
+	moved := comments{}
+	for key, texts := range cs {
+		if strings.HasPrefix(key, prefix+"/") {
+			moved[newPrefix+key[len(prefix):]] = texts
+		}
+	}
+	return moved
+}
+
+// merge returns the comments of both, in case of the
+// same paths the own comments win.
+func (cs comments) merge(other comments) comments {
+	merged := comments{}
+	for key, texts := range other {
+		merged[key] = texts
+	}
+	for key, texts := range cs {
+		merged[key] = texts
+	}
+	return merged
+}
+
+// EOF
@@ -0,0 +1,38 @@
+// Tideland Go Library - Etc - Context
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+)
+
+//--------------------
+// CONTEXT
+//--------------------
+
+// key is to address a configuration inside a context.
+type key int
+
+var etcKey key = 0
+
+// NewContext returns a new context that carries a configuration.
+func NewContext(ctx context.Context, cfg Etc) context.Context {
+	return context.WithValue(ctx, etcKey, cfg)
+}
+
+// FromContext returns the configuration stored in ctx, if any.
+func FromContext(ctx context.Context) (Etc, bool) {
+	cfg, ok := ctx.Value(etcKey).(Etc)
+	return cfg, ok
+}
+
+// EOF
@@ -0,0 +1,143 @@
+// Tideland Go Library - Etc - Diff
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//--------------------
+// CHANGE
+//--------------------
+
+// ChangeKind describes how a path differs between two
+// configurations.
+type ChangeKind int
+
+const (
+	// ChangeAdded marks a path only existing in the other
+	// configuration.
+	ChangeAdded ChangeKind = iota
+
+	// ChangeRemoved marks a path only existing in the own
+	// configuration.
+	ChangeRemoved
+
+	// ChangeModified marks a path existing in both
+	// configurations with different values.
+	ChangeModified
+)
+
+var changeKindNames = map[ChangeKind]string{
+	ChangeAdded:    "added",
+	ChangeRemoved:  "removed",
+	ChangeModified: "modified",
+}
+
+// String implements the fmt.Stringer interface.
+func (ck ChangeKind) String() string {
+	if name, ok := changeKindNames[ck]; ok {
+		return name
+	}
+	return fmt.Sprintf("change(%d)", int(ck))
+}
+
+// Change describes the difference at one path. The path is
+// slash separated without the leading "etc". Old is empty for
+// added paths, New for removed ones.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Old  string
+	New  string
+}
+
+// String implements the fmt.Stringer interface.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%s %s: %q", c.Kind, c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%s %s: %q", c.Kind, c.Path, c.Old)
+	}
+	return fmt.Sprintf("%s %s: %q -> %q", c.Kind, c.Path, c.Old, c.New)
+}
+
+//--------------------
+// DIFF
+//--------------------
+
+// Diff implements the Etc interface.
+func (e *etc) Diff(other Etc) []Change {
+	ovs, ors := diffValues(other)
+	evs, ers := diffValues(e)
+	paths := []string{}
+	for path := range evs {
+		paths = append(paths, path)
+	}
+	for path := range ovs {
+		if _, ok := evs[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	changes := []Change{}
+	for _, path := range paths {
+		ev, inE := evs[path]
+		ov, inO := ovs[path]
+		if ers[path] {
+			ev = redactedValue
+		}
+		if ors[path] {
+			ov = redactedValue
+		}
+		switch {
+		case !inE:
+			changes = append(changes, Change{Path: path, Kind: ChangeAdded, New: ov})
+		case !inO:
+			changes = append(changes, Change{Path: path, Kind: ChangeRemoved, Old: ev})
+		case evs[path] != ovs[path]:
+			changes = append(changes, Change{Path: path, Kind: ChangeModified, Old: ev, New: ov})
+		}
+	}
+	return changes
+}
+
+// diffValues returns the raw values of all nodes of the configuration
+// by their paths and the paths of the redacted values. So changed
+// redacted values are detected but not reported.
+func diffValues(cfg Etc) (Application, map[string]bool) {
+	e, ok := cfg.(*etc)
+	if !ok {
+		appl, _ := cfg.Dump()
+		return appl, nil
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	appl := Application{}
+	redacted := map[string]bool{}
+	e.values.DoAllDeep(func(ks []string, v string) error {
+		if len(ks) > 1 {
+			path := strings.Join(ks[1:], "/")
+			appl[path] = v
+			if v != "" && e.isRedacted(ks) {
+				redacted[path] = true
+			}
+		}
+		return nil
+	})
+	return appl, redacted
+}
+
+// EOF
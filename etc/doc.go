@@ -28,6 +28,12 @@
 //
 // The leading "etc" node of the path is set by default.
 //
+// All typed accessors return the passed default value if the path doesn't
+// exist or the value cannot be parsed, so optional settings need no error
+// handling. Only ValueAsString() differs for existing but empty values like
+// {max-users}, here the empty string is returned. HasPath() helps if a
+// missing path has to be distinguished from an unparsable value.
+//
 // If values contain templates formatted [<env-or-path>||<default>] the
 // configuration tries to read the value out of the environment (if the
 // name starts with a dollar sign) or given path inside the configuration.
@@ -43,6 +49,26 @@
 // leads to "/var/lib/myserver/service-a" and if the base directory
 // isn't set to "./service-a". If nothing is set the default value
 // is the "." passed in the method call.
+//
+// Additionally values can reference other values with ${path}. These
+// references are replaced when the values are retrieved or when calling
+// Expand(). As braces have to be escaped in SML they are written like
+//
+//     {errlog $^{logdir^}/error.log}
+//
+// A literal "${" is written as "$${".
+//
+// Configuration files read with ReadFile() can include other files
+// with the comment {#@include <filename>#}. Relative filenames are
+// resolved from the including file. The nodes below the root of the
+// included file are added at the place of the comment, like
+//
+//     {etc
+//         {global {#@include global.sml#}}
+//     }
+//
+// Circular includes lead to an error naming the chain of files. Other
+// readers keep the include comments as ordinary comments.
 package etc
 
 // EOF
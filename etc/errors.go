@@ -27,16 +27,52 @@ const (
 	ErrInvalidPath
 	ErrCannotSplit
 	ErrCannotApply
+	ErrIllegalSourceKey
+	ErrCannotConvertSource
+	ErrIllegalValue
+	ErrIllegalSeparator
+	ErrCannotMerge
+	ErrCannotWrite
+	ErrCannotSet
+	ErrInvalidConfiguration
+	ErrIllegalPollTime
+	ErrCannotExpand
+	ErrCircularReference
+	ErrIllegalTarget
+	ErrCannotUnmarshal
+	ErrIllegalPattern
+	ErrDuplicateKey
+	ErrKeyConflict
+	ErrCannotInclude
+	ErrCircularInclude
 )
 
 var errorMessages = errors.Messages{
-	ErrIllegalSourceFormat: "illegal source format",
-	ErrIllegalConfigSource: "illegal source for configuration: %v",
-	ErrCannotReadFile:      "cannot read configuration file %q",
-	ErrCannotPostProcess:   "cannot post-process configuration: %q",
-	ErrInvalidPath:         "invalid configuration path %q",
-	ErrCannotSplit:         "cannot split configuration",
-	ErrCannotApply:         "cannot apply values to configuration",
+	ErrIllegalSourceFormat:  "illegal source format",
+	ErrIllegalConfigSource:  "illegal source for configuration: %v",
+	ErrCannotReadFile:       "cannot read configuration file %q",
+	ErrCannotPostProcess:    "cannot post-process configuration: %q",
+	ErrInvalidPath:          "invalid configuration path %q, part %q not found",
+	ErrCannotSplit:          "cannot split configuration at %q: %v",
+	ErrCannotApply:          "cannot apply values to configuration",
+	ErrIllegalSourceKey:     "illegal configuration key %q",
+	ErrCannotConvertSource:  "cannot convert %s configuration source: %v",
+	ErrIllegalValue:         "value %q at %q is no %s",
+	ErrIllegalSeparator:     "illegal path separator %q",
+	ErrCannotMerge:          "cannot merge configurations",
+	ErrCannotWrite:          "cannot write configuration",
+	ErrCannotSet:            "cannot set value at %q: %s",
+	ErrInvalidConfiguration: "invalid configuration",
+	ErrIllegalPollTime:      "illegal poll time %v",
+	ErrCannotExpand:         "cannot expand configuration",
+	ErrCircularReference:    "circular reference %s",
+	ErrIllegalTarget:        "illegal unmarshal target: %s",
+	ErrCannotUnmarshal:      "cannot unmarshal configuration",
+	ErrIllegalPattern:       "illegal pattern %q",
+	ErrDuplicateKey:         "duplicate configuration key %q",
+	ErrKeyConflict:          "keys %q and %q at %q only differ in case",
+	ErrCannotInclude:        "cannot include %q: %v",
+	ErrCircularInclude:      "circular include %s",
 }
 
 //--------------------
@@ -48,4 +84,10 @@ func IsInvalidPathError(err error) bool {
 	return errors.IsError(err, ErrInvalidPath)
 }
 
+// IsInvalidConfigurationError checks if a configuration
+// failed the validation.
+func IsInvalidConfigurationError(err error) bool {
+	return errors.IsError(err, ErrInvalidConfiguration)
+}
+
 // EOF
@@ -12,13 +12,19 @@ package etc
 //--------------------
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tideland/golib/collections"
@@ -31,31 +37,63 @@ import (
 // GLOBAL
 //--------------------
 
-// key is to address a configuration inside a context.
-type key int
-
 var (
-	etcKey    key = 0
-	etcRoot       = []string{"etc"}
-	defaulter     = stringex.NewDefaulter("etc", false)
+	etcRoot   = []string{"etc"}
+	defaulter = stringex.NewDefaulter("etc", false)
 )
 
+// defaultSeparator separates the parts of a path if
+// no other separator is set.
+const defaultSeparator = "/"
+
+// redactedValue replaces redacted values in outputs.
+const redactedValue = "***"
+
 //--------------------
 // VALUE
 //--------------------
 
 // value helps to use the stringex.Defaulter.
 type value struct {
-	path    []string
-	changer collections.KeyStringValueChanger
+	etc  *etc
+	path []string
 }
 
 // Value retrieves the value or an error. It implements
 // the Valuer interface.
 func (v *value) Value() (string, error) {
-	sv, err := v.changer.Value()
+	v.etc.mutex.RLock()
+	defer v.etc.mutex.RUnlock()
+	changer, err := v.etc.lookup(v.path)
+	if err != nil {
+		return "", err
+	}
+	sv, err := changer.Value()
+	if err != nil {
+		return "", err
+	}
+	return v.etc.expand(sv)
+}
+
+// boolValue normalizes the additional spellings of bool
+// values before they are parsed by the stringex.Defaulter.
+type boolValue struct {
+	valuer stringex.Valuer
+}
+
+// Value retrieves the normalized value or an error. It
+// implements the Valuer interface.
+func (bv *boolValue) Value() (string, error) {
+	sv, err := bv.valuer.Value()
 	if err != nil {
-		return "", errors.New(ErrInvalidPath, errorMessages, pathToString(v.path))
+		return "", err
+	}
+	sv = strings.TrimSpace(sv)
+	switch strings.ToLower(sv) {
+	case "true", "yes", "on", "1":
+		return "true", nil
+	case "false", "no", "off", "0":
+		return "false", nil
 	}
 	return sv, nil
 }
@@ -67,47 +105,220 @@ func (v *value) Value() (string, error) {
 // Application is used to apply values to a configurtation.
 type Application map[string]string
 
+// Type defines the expected type of a value when
+// validating a configuration.
+type Type int
+
+// Types of values to validate.
+const (
+	TypeString Type = iota
+	TypeBool
+	TypeInt
+	TypeFloat64
+	TypeTime
+	TypeDuration
+	TypeBytes
+)
+
+// typeNames maps the types to readable names.
+var typeNames = map[Type]string{
+	TypeString:   "string",
+	TypeBool:     "bool",
+	TypeInt:      "int",
+	TypeFloat64:  "float64",
+	TypeTime:     "time",
+	TypeDuration: "duration",
+	TypeBytes:    "bytes",
+}
+
+// String implements the fmt.Stringer interface.
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", int(t))
+}
+
+// ListMerging defines how lists are handled when merging
+// configurations. Lists are nodes with the children "0", "1",
+// and so on, like they are created by the JSON or YAML readers.
+type ListMerging int
+
+const (
+	// ReplaceLists lets lists of later configurations
+	// replace the whole list of the earlier ones.
+	ReplaceLists ListMerging = iota
+
+	// AppendLists appends the elements of lists of later
+	// configurations to the lists of the earlier ones.
+	AppendLists
+)
+
 // Etc contains the read etc configuration and provides access to
 // it. ThetcRoot node "etc" is automatically preceded to the path.
 // The node name have to consist out of 'a' to 'z', '0' to '9', and
-// '-'. The nodes of a path are separated by '/'.
+// '-'. The nodes of a path are separated by '/' or the separator
+// set with SetPathSeparator().
 type Etc interface {
+	// String returns the configuration as indented SML like
+	// written by Write(), only without comments. Redacted
+	// values are returned as "***".
 	fmt.Stringer
 
+	// MarshalJSON returns the configuration as JSON object. Nodes
+	// with children become objects, lists with the keys "0", "1",
+	// and so on become arrays, and all values become strings. Values
+	// of nodes with children are dropped. Nodes without children and
+	// value become empty arrays, like ValuesAt() reads them as empty
+	// lists. The structure is the same as the one of String(),
+	// redacted values are returned as "***".
+	json.Marshaler
+
+	// SetPathSeparator changes the separator of the path parts for
+	// all methods retrieving values or subconfigurations. Default
+	// is "/". The paths of an Application always use "/". The
+	// separator should be set directly after reading, it is
+	// inherited by subconfigurations.
+	SetPathSeparator(sep string) error
+
+	// SetCaseInsensitive lets all methods find the nodes regardless
+	// of the case of their keys. Default is case-sensitive, requested
+	// paths are lowercased then. Enabling it fails if keys of the same
+	// node only differ in case. Like the separator it should be set
+	// directly after reading and it is inherited.
+	SetCaseInsensitive(on bool) error
+
 	// HasPath checks if the configurations has the defined path
 	// regardles of the value or possible subconfigurations.
 	HasPath(path string) bool
 
 	// ValueAsString retrieves the string value at a given path. If it
-	// doesn't exist the default value dv is returned.
+	// doesn't exist the default value dv is returned. An existing but
+	// empty value is returned as empty string.
 	ValueAsString(path, dv string) string
 
-	// ValueAsBool retrieves the bool value at a given path. If it
-	// doesn't exist the default value dv is returned.
+	// ValueAsBool retrieves the bool value at a given path. Beside
+	// the formats of strconv.ParseBool() it accepts "yes", "no",
+	// "on", and "off" case-insensitively, surrounding whitespace is
+	// ignored. If it doesn't exist or cannot be parsed the default
+	// value dv is returned.
 	ValueAsBool(path string, dv bool) bool
 
 	// ValueAsInt retrieves the int value at a given path. If it
-	// doesn't exist the default value dv is returned.
+	// doesn't exist or cannot be parsed the default value dv is returned.
 	ValueAsInt(path string, dv int) int
 
 	// ValueAsFloat64 retrieves the float64 value at a given path. If it
-	// doesn't exist the default value dv is returned.
+	// doesn't exist or cannot be parsed the default value dv is returned.
 	ValueAsFloat64(path string, dv float64) float64
 
 	// ValueAsTime retrieves the string value at a given path and
-	// interprets it as time with the passed format. If it
-	// doesn't exist the default value dv is returned.
+	// interprets it as time with the passed format. If it doesn't
+	// exist or cannot be parsed the default value dv is returned.
 	ValueAsTime(path, layout string, dv time.Time) time.Time
 
-	// ValueAsDuration retrieves the duration value at a given path.
-	// If it doesn't exist the default value dv is returned.
+	// ValueAsDuration retrieves the duration value at a given path. If it
+	// doesn't exist or cannot be parsed the default value dv is returned.
 	ValueAsDuration(path string, dv time.Duration) time.Duration
 
+	// ValueAsBytes retrieves the value at a given path as number of
+	// bytes. Units can be the SI ones KB, MB, GB, TB, and PB or the
+	// binary ones KiB, MiB, GiB, TiB, and PiB, case-insensitively.
+	// Values without unit or with B are bytes, fractions like 1.5GB
+	// are allowed. If it doesn't exist or cannot be parsed the
+	// default value dv is returned.
+	ValueAsBytes(path string, dv int64) int64
+
+	// ValuesAt retrieves the values of the child nodes at a given
+	// path in document order. If the path points to a single value
+	// it is returned as only element, an empty node returns an empty
+	// slice. A missing path leads to an error.
+	ValuesAt(path string) ([]string, error)
+
+	// IntValuesAt retrieves the values like ValuesAt() and
+	// interprets them as int.
+	IntValuesAt(path string) ([]int, error)
+
+	// Float64ValuesAt retrieves the values like ValuesAt() and
+	// interprets them as float64.
+	Float64ValuesAt(path string) ([]float64, error)
+
+	// Validate checks if all required paths exist. Otherwise the
+	// returned error contains all missing paths.
+	Validate(required []string) error
+
+	// ValidateTypes checks if all paths exist and if their values can
+	// be parsed as the given types. Times have to be formatted as
+	// RFC 3339. Otherwise the returned error contains all failures.
+	ValidateTypes(required map[string]Type) error
+
+	// Expand creates a new configuration where all references to
+	// other values are replaced by those values. Otherwise they are
+	// replaced when retrieving a value. References are formatted
+	// ${path}, always using slashes as separator, and "$${" leads
+	// to a literal "${". Missing or circular references lead to
+	// an error.
+	Expand() (Etc, error)
+
+	// Unmarshal sets the exported fields of the struct the target
+	// points to. The field paths are defined by the tag
+	// `etc:"<path>[,required]"` relative to the path of the struct,
+	// default is the field name. The tag "-" skips a field. Nested
+	// structs are set out of subconfigurations, slices out of the
+	// child values or a single value. Fields with missing paths stay
+	// unchanged as long as they aren't required, also inside of
+	// missing nested structs. Times have to be formatted as RFC 3339.
+	Unmarshal(target interface{}) error
+
+	// Set stores the value at the given path, missing nodes are
+	// created. The value is stored in its string representation,
+	// so it can be retrieved by the typed accessors. Times are
+	// formatted as RFC 3339. Paths containing a subconfiguration
+	// cannot be set.
+	Set(path string, value interface{}) error
+
+	// Keys returns the keys of the child nodes at the given path
+	// in document order. An empty path returns the top level keys.
+	Keys(path string) ([]string, error)
+
+	// Walk calls fn for all values without child nodes in document
+	// order, using the path separator. The values are in their raw
+	// form, references aren't expanded, and redacted values are
+	// passed as "***". The first error returned by fn stops the
+	// walk and is returned.
+	Walk(fn func(path, value string) error) error
+
+	// Match returns the sorted paths of all values without child
+	// nodes matching the glob, using the path separator. Each part
+	// of the glob may contain wildcards like in path.Match() while
+	// a "**" part matches any number of parts, so "service/*/timeout"
+	// matches the timeouts of all services and "**/timeout" all
+	// timeouts.
+	Match(glob string) ([]string, error)
+
+	// Redact marks the values matching the patterns as sensitive. The
+	// patterns are slash separated paths where each part can contain
+	// wildcards like in path.Match() or can be "**" for any number of
+	// parts like in Match(). A matching path also redacts
+	// all values below. Redacted values are replaced by "***" when
+	// writing, printing, or walking the configuration, while the
+	// accessors and Dump() still return the real values. Applied,
+	// expanded, and splitted configurations keep the patterns, the
+	// latter relative to their new root. Merged ones combine the
+	// patterns of all configurations.
+	Redact(patterns ...string) error
+
+	// Clone creates an independent deep copy of the configuration
+	// including the path separator and the redactions. Changes of
+	// the clone don't change this configuration and vice versa.
+	Clone() Etc
+
 	// Spit produces a subconfiguration below the passed path.
 	// The last path part will be the new root, all values below
 	// that configuration node will be below the created root.
 	// In case of an invalid path an empty configuration will
-	// be returned as default.
+	// be returned as default. The subconfiguration is independent
+	// of this one. A path pointing to a value leads to an error.
 	Split(path string) (Etc, error)
 
 	// Dunp creates a map of paths and their values to apply
@@ -118,17 +329,109 @@ type Etc interface {
 	// the passed values. The keys of the map have to be slash
 	// separated configuration paths without the leading "etc".
 	Apply(appl Application) (Etc, error)
+
+	// ApplyEnvOverrides creates a new configuration by applying the
+	// environment variables starting with the prefix and an underscore.
+	// The rest of the name is lowercased and split into the path parts
+	// at each underscore, a double underscore stands for a dash. So
+	// with prefix "MYAPP" the variable MYAPP_SERVICE_DB__HOST overrides
+	// the path "service/db-host". As node names cannot contain
+	// underscores there are no collisions. The returned application
+	// contains all overridden paths and their values.
+	ApplyEnvOverrides(prefix string) (Etc, Application, error)
+
+	// Merge creates a new configuration out of this one and the
+	// passed ones. Values of later configurations override those of
+	// earlier ones at the same path, all other nodes are combined.
+	// Lists are merged like defined by lm. The merged configurations
+	// stay unchanged.
+	Merge(lm ListMerging, others ...Etc) (Etc, error)
+
+	// OnChange registers fn to be called when the value at the path
+	// changes through Set() or when a configuration merged out of
+	// this one with Merge() has a different value there. Multiple
+	// functions for the same path are called in the order of their
+	// registration, after the configuration has been unlocked. The
+	// returned ID is needed to remove the function again. Other
+	// configurations created out of this one don't inherit the
+	// registered functions.
+	OnChange(path string, fn ChangeFunc) int
+
+	// RemoveOnChange removes the function registered with
+	// the passed ID.
+	RemoveOnChange(id int)
+
+	// Diff compares this configuration with the other one and
+	// returns the changes of all nodes, including the ones of
+	// added or removed subconfigurations, sorted by their paths.
+	// Values are compared unredacted, but redacted values of
+	// either configuration are passed as "***".
+	Diff(other Etc) []Change
 }
 
 // etc implements the Etc interface.
 type etc struct {
-	values collections.KeyStringValueTree
+	mutex           sync.RWMutex
+	values          collections.KeyStringValueTree
+	separator       string
+	expanded        bool
+	redactions      []string
+	comments        comments
+	caseInsensitive bool
+	listeners       []listener
+	listenerID      int
 }
 
 // Read reads the SML source of the configuration from a
-// reader, parses it, and returns the etc instance.
+// reader, parses it, and returns the etc instance. Nodes
+// defined more than once are combined.
 func Read(source io.Reader) (Etc, error) {
-	builder := sml.NewKeyStringValueTreeBuilder()
+	return read(source, newBuilder(false, ""))
+}
+
+// ReadStrict reads the SML source of the configuration like
+// Read() but returns an error naming the first path defined
+// more than once. Lists have to use distinct keys like "0",
+// "1", and so on.
+func ReadStrict(source io.Reader) (Etc, error) {
+	return read(source, newBuilder(true, ""))
+}
+
+// ReadString reads the SML source of the configuration from a
+// string, parses it, and returns the etc instance.
+func ReadString(source string) (Etc, error) {
+	return Read(strings.NewReader(source))
+}
+
+// ReadFile reads the SML source of a configuration file,
+// parses it, and returns the etc instance. The file may
+// include other files with the comment {#@include <filename>#},
+// relative filenames are resolved from the including file. Other
+// readers keep those comments as ordinary ones.
+// The nodes below the root of the included file are added
+// to the node containing the comment.
+func ReadFile(filename string) (Etc, error) {
+	return readFile(filename, false)
+}
+
+// ReadFileStrict reads the SML source of a configuration
+// file like ReadFile() but strict like ReadStrict().
+func ReadFileStrict(filename string) (Etc, error) {
+	return readFile(filename, true)
+}
+
+// readFile reads and parses the SML source of a
+// configuration file.
+func readFile(filename string, strict bool) (Etc, error) {
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Annotate(err, ErrCannotReadFile, errorMessages, filename)
+	}
+	return read(bytes.NewReader(source), newBuilder(strict, filename))
+}
+
+// read reads and parses the SML source of the configuration.
+func read(source io.Reader, builder *builder) (Etc, error) {
 	err := sml.ReadSML(source, builder)
 	if err != nil {
 		return nil, errors.Annotate(err, ErrIllegalSourceFormat, errorMessages)
@@ -141,7 +444,9 @@ func Read(source io.Reader) (Etc, error) {
 		return nil, errors.Annotate(err, ErrIllegalSourceFormat, errorMessages)
 	}
 	cfg := &etc{
-		values: values,
+		values:    values,
+		separator: defaultSeparator,
+		comments:  builder.comments,
 	}
 	if err = cfg.postProcess(); err != nil {
 		return nil, errors.Annotate(err, ErrCannotPostProcess, errorMessages)
@@ -149,25 +454,72 @@ func Read(source io.Reader) (Etc, error) {
 	return cfg, nil
 }
 
-// ReadString reads the SML source of the configuration from a
-// string, parses it, and returns the etc instance.
-func ReadString(source string) (Etc, error) {
-	return Read(strings.NewReader(source))
+// SetPathSeparator implements the Etc interface.
+func (e *etc) SetPathSeparator(sep string) error {
+	if sep == "" {
+		return errors.New(ErrIllegalSeparator, errorMessages, sep)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.separator = sep
+	return nil
 }
 
-// ReadFile reads the SML source of a configuration file,
-// parses it, and returns the etc instance.
-func ReadFile(filename string) (Etc, error) {
-	source, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, errors.Annotate(err, ErrCannotReadFile, errorMessages, filename)
+// SetCaseInsensitive implements the Etc interface.
+func (e *etc) SetCaseInsensitive(on bool) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if on {
+		err := e.values.DoAllDeep(func(ks []string, v string) error {
+			children, err := e.values.At(ks...).List()
+			if err != nil {
+				return err
+			}
+			for i, child := range children {
+				for _, other := range children[:i] {
+					if strings.EqualFold(child.Key, other.Key) {
+						return errors.New(ErrKeyConflict, errorMessages, other.Key, child.Key, pathToString(ks))
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
-	return ReadString(string(source))
+	e.caseInsensitive = on
+	return nil
+}
+
+// Write writes the configuration as SML to the target. Nodes
+// are written in the order of the configuration, each child one
+// tab deeper on its own line. So reading the written SML and
+// writing it again leads to the same output. Redacted values
+// are written as "***". Comments of read SML sources are
+// written in front of the same nodes again.
+func Write(target io.Writer, cfg Etc) error {
+	e, ok := cfg.(*etc)
+	if !ok {
+		return errors.New(ErrIllegalConfigSource, errorMessages, fmt.Sprintf("%T", cfg))
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	enc := newSMLEncoder()
+	if err := writeValues(enc, e.redactedValues(), etcRoot, e.comments); err != nil {
+		return errors.Annotate(err, ErrCannotWrite, errorMessages)
+	}
+	if _, err := enc.bytes().WriteTo(target); err != nil {
+		return errors.Annotate(err, ErrCannotWrite, errorMessages)
+	}
+	return nil
 }
 
 // HasPath implements the Etc interface.
 func (e *etc) HasPath(path string) bool {
-	fullPath := makeFullPath(path)
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	fullPath := e.fullPath(path, e.separator)
 	changer := e.values.At(fullPath...)
 	return changer.Error() == nil
 }
@@ -181,7 +533,7 @@ func (e *etc) ValueAsString(path, dv string) string {
 // ValueAsBool implements the Etc interface.
 func (e *etc) ValueAsBool(path string, dv bool) bool {
 	value := e.valueAt(path)
-	return defaulter.AsBool(value, dv)
+	return defaulter.AsBool(&boolValue{value}, dv)
 }
 
 // ValueAsInt implements the Etc interface.
@@ -208,26 +560,349 @@ func (e *etc) ValueAsDuration(path string, dv time.Duration) time.Duration {
 	return defaulter.AsDuration(value, dv)
 }
 
+// ValueAsBytes implements the Etc interface.
+func (e *etc) ValueAsBytes(path string, dv int64) int64 {
+	sv, err := e.valueAt(path).Value()
+	if err != nil {
+		return dv
+	}
+	bytes, err := parseBytes(sv)
+	if err != nil {
+		return dv
+	}
+	return bytes
+}
+
+// ValuesAt implements the Etc interface.
+func (e *etc) ValuesAt(path string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	changer, err := e.lookup(e.fullPath(path, e.separator))
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := changer.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(kvs) == 0 {
+		sv, _ := changer.Value()
+		if sv == "" {
+			return []string{}, nil
+		}
+		kvs = []collections.KeyStringValue{{Value: sv}}
+	}
+	svs := make([]string, len(kvs))
+	for i, kv := range kvs {
+		if svs[i], err = e.expand(kv.Value); err != nil {
+			return nil, err
+		}
+	}
+	return svs, nil
+}
+
+// IntValuesAt implements the Etc interface.
+func (e *etc) IntValuesAt(path string) ([]int, error) {
+	svs, err := e.ValuesAt(path)
+	if err != nil {
+		return nil, err
+	}
+	ivs := make([]int, len(svs))
+	for i, sv := range svs {
+		iv, err := strconv.Atoi(sv)
+		if err != nil {
+			return nil, errors.Annotate(err, ErrIllegalValue, errorMessages, sv, path, "int")
+		}
+		ivs[i] = iv
+	}
+	return ivs, nil
+}
+
+// Float64ValuesAt implements the Etc interface.
+func (e *etc) Float64ValuesAt(path string) ([]float64, error) {
+	svs, err := e.ValuesAt(path)
+	if err != nil {
+		return nil, err
+	}
+	fvs := make([]float64, len(svs))
+	for i, sv := range svs {
+		fv, err := strconv.ParseFloat(sv, 64)
+		if err != nil {
+			return nil, errors.Annotate(err, ErrIllegalValue, errorMessages, sv, path, "float64")
+		}
+		fvs[i] = fv
+	}
+	return fvs, nil
+}
+
+// Validate implements the Etc interface.
+func (e *etc) Validate(required []string) error {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	var errs []error
+	for _, path := range required {
+		if _, err := e.lookup(e.fullPath(path, e.separator)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Annotate(errors.Collect(errs...), ErrInvalidConfiguration, errorMessages)
+	}
+	return nil
+}
+
+// ValidateTypes implements the Etc interface.
+func (e *etc) ValidateTypes(required map[string]Type) error {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	paths := []string{}
+	for path := range required {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var errs []error
+	for _, path := range paths {
+		changer, err := e.lookup(e.fullPath(path, e.separator))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sv, err := changer.Value()
+		if err == nil {
+			sv, err = e.expand(sv)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = checkType(sv, required[path]); err != nil {
+			errs = append(errs, errors.Annotate(err, ErrIllegalValue, errorMessages, sv, path, required[path]))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Annotate(errors.Collect(errs...), ErrInvalidConfiguration, errorMessages)
+	}
+	return nil
+}
+
+// Expand implements the Etc interface.
+func (e *etc) Expand() (Etc, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	ec := &etc{
+		values:          e.values.Copy(),
+		separator:       e.separator,
+		expanded:        true,
+		redactions:      append([]string{}, e.redactions...),
+		comments:        e.comments,
+		caseInsensitive: e.caseInsensitive,
+	}
+	changers := ec.values.FindAll(func(k, v string) (bool, error) {
+		return strings.Contains(v, "${"), nil
+	})
+	for _, changer := range changers {
+		sv, err := changer.Value()
+		if err != nil {
+			return nil, errors.Annotate(err, ErrCannotExpand, errorMessages)
+		}
+		if sv, err = e.expand(sv); err != nil {
+			return nil, errors.Annotate(err, ErrCannotExpand, errorMessages)
+		}
+		if _, err = changer.SetValue(sv); err != nil {
+			return nil, errors.Annotate(err, ErrCannotExpand, errorMessages)
+		}
+	}
+	return ec, nil
+}
+
+// Set implements the Etc interface.
+func (e *etc) Set(path string, value interface{}) error {
+	e.mutex.Lock()
+	changed, err := e.set(path, value)
+	e.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	notifyChanges(changed)
+	return nil
+}
+
+// set stores the value and returns the listeners
+// whose values changed.
+func (e *etc) set(path string, value interface{}) ([]listener, error) {
+	fullPath := e.fullPath(path, e.separator)
+	if len(fullPath) == 1 {
+		return nil, errors.New(ErrCannotSet, errorMessages, pathToString(fullPath), "path is the root")
+	}
+	// Check for existing values on the way and a
+	// subconfiguration at the end.
+	for i := 2; i <= len(fullPath); i++ {
+		changer := e.values.At(fullPath[:i]...)
+		if changer.Error() != nil {
+			break
+		}
+		if i < len(fullPath) {
+			if sv, _ := changer.Value(); sv != "" {
+				return nil, errors.New(ErrCannotSet, errorMessages, pathToString(fullPath), "path contains a value")
+			}
+			continue
+		}
+		if children, _ := changer.List(); len(children) > 0 {
+			return nil, errors.New(ErrCannotSet, errorMessages, pathToString(fullPath), "path contains a subconfiguration")
+		}
+	}
+	ls := e.watchedValues()
+	_, err := e.values.Create(fullPath...).SetValue(valueToString(value))
+	if err != nil {
+		return nil, errors.Annotate(err, ErrCannotSet, errorMessages, pathToString(fullPath), "node cannot be set")
+	}
+	return changedValues(ls, e.values), nil
+}
+
+// Keys implements the Etc interface.
+func (e *etc) Keys(path string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	changer, err := e.lookup(e.fullPath(path, e.separator))
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := changer.List()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(kvs))
+	for i, kv := range kvs {
+		keys[i] = kv.Key
+	}
+	return keys, nil
+}
+
+// Walk implements the Etc interface.
+func (e *etc) Walk(fn func(path, value string) error) error {
+	// Collect the leafs first so that fn is
+	// free to use the configuration.
+	e.mutex.RLock()
+	var leafs []collections.KeyStringValue
+	values := e.redactedValues()
+	err := values.DoAllDeep(func(ks []string, v string) error {
+		if len(ks) == 1 {
+			return nil
+		}
+		if children, _ := values.At(ks...).List(); len(children) == 0 {
+			leafs = append(leafs, collections.KeyStringValue{
+				Key:   strings.Join(ks[1:], e.separator),
+				Value: v,
+			})
+		}
+		return nil
+	})
+	e.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leafs {
+		if err := fn(leaf.Key, leaf.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match implements the Etc interface.
+func (e *etc) Match(glob string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	pattern := makeFullPath(glob, e.separator)[1:]
+	for _, part := range pattern {
+		if _, err := path.Match(part, ""); err != nil {
+			return nil, errors.Annotate(err, ErrIllegalPattern, errorMessages, glob)
+		}
+	}
+	matches := []string{}
+	err := e.values.DoAllDeep(func(ks []string, v string) error {
+		if len(ks) == 1 {
+			return nil
+		}
+		if children, _ := e.values.At(ks...).List(); len(children) > 0 {
+			return nil
+		}
+		parts := ks[1:]
+		if e.caseInsensitive {
+			parts = strings.Split(strings.ToLower(strings.Join(parts, "/")), "/")
+		}
+		if matchGlob(pattern, parts) {
+			matches = append(matches, strings.Join(ks[1:], e.separator))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Redact implements the Etc interface.
+func (e *etc) Redact(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Annotate(err, ErrIllegalPattern, errorMessages, pattern)
+		}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.redactions = append(e.redactions, patterns...)
+	return nil
+}
+
+// Clone implements the Etc interface.
+func (e *etc) Clone() Etc {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return &etc{
+		values:          e.values.Copy(),
+		separator:       e.separator,
+		expanded:        e.expanded,
+		redactions:      append([]string{}, e.redactions...),
+		comments:        e.comments,
+		caseInsensitive: e.caseInsensitive,
+	}
+}
+
 // Split implements the Etc interface.
 func (e *etc) Split(path string) (Etc, error) {
-	if !e.HasPath(path) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	fullPath := e.fullPath(path, e.separator)
+	changer := e.values.At(fullPath...)
+	if changer.Error() != nil {
 		// Path not found, return empty configuration.
 		return ReadString("{etc}")
 	}
-	fullPath := makeFullPath(path)
+	if sv, _ := changer.Value(); sv != "" {
+		return nil, errors.New(ErrCannotSplit, errorMessages, pathToString(fullPath), "path contains a value")
+	}
 	values, err := e.values.CopyAt(fullPath...)
 	if err != nil {
-		return nil, errors.Annotate(err, ErrCannotSplit, errorMessages)
+		return nil, errors.Annotate(err, ErrCannotSplit, errorMessages, pathToString(fullPath), err)
 	}
 	values.At(fullPath[len(fullPath)-1:]...).SetKey("etc")
 	es := &etc{
-		values: values,
+		values:          values,
+		separator:       e.separator,
+		expanded:        e.expanded,
+		redactions:      rerootRedactions(e.redactions, fullPath[1:]),
+		comments:        e.comments.move(strings.Join(fullPath, "/"), "etc"),
+		caseInsensitive: e.caseInsensitive,
 	}
 	return es, nil
 }
 
 // Dump implements the Etc interface.
 func (e *etc) Dump() (Application, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	appl := Application{}
 	err := e.values.DoAllDeep(func(ks []string, v string) error {
 		if len(ks) == 1 {
@@ -246,11 +921,18 @@ func (e *etc) Dump() (Application, error) {
 
 // Apply implements the Etc interface.
 func (e *etc) Apply(appl Application) (Etc, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	ec := &etc{
-		values: e.values.Copy(),
+		values:          e.values.Copy(),
+		separator:       e.separator,
+		expanded:        e.expanded,
+		redactions:      append([]string{}, e.redactions...),
+		comments:        e.comments,
+		caseInsensitive: e.caseInsensitive,
 	}
 	for path, value := range appl {
-		fullPath := makeFullPath(path)
+		fullPath := ec.fullPath(path, defaultSeparator)
 		_, err := ec.values.Create(fullPath...).SetValue(value)
 		if err != nil {
 			return nil, errors.Annotate(err, ErrCannotApply, errorMessages)
@@ -259,17 +941,279 @@ func (e *etc) Apply(appl Application) (Etc, error) {
 	return ec, nil
 }
 
-// Apply implements the Stringer interface.
+// ApplyEnvOverrides implements the Etc interface.
+func (e *etc) ApplyEnvOverrides(prefix string) (Etc, Application, error) {
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	appl := Application{}
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], prefix) {
+			continue
+		}
+		name := strings.ToLower(kv[0][len(prefix):])
+		name = strings.Replace(name, "__", "-", -1)
+		path := strings.Join(stringex.SplitFilter(name, "_", func(p string) bool {
+			return p != ""
+		}), "/")
+		if path == "" {
+			continue
+		}
+		appl[path] = kv[1]
+	}
+	ec, err := e.Apply(appl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ec, appl, nil
+}
+
+// Merge implements the Etc interface.
+func (e *etc) Merge(lm ListMerging, others ...Etc) (Etc, error) {
+	e.mutex.RLock()
+	em := &etc{
+		values:          e.values.Copy(),
+		separator:       e.separator,
+		expanded:        e.expanded,
+		redactions:      append([]string{}, e.redactions...),
+		comments:        e.comments,
+		caseInsensitive: e.caseInsensitive,
+	}
+	ls := e.watchedValues()
+	e.mutex.RUnlock()
+	for _, other := range others {
+		eo, ok := other.(*etc)
+		if !ok {
+			return nil, errors.New(ErrIllegalConfigSource, errorMessages, fmt.Sprintf("%T", other))
+		}
+		eo.mutex.RLock()
+		err := mergeValues(em.values, etcRoot, eo.values, etcRoot, lm)
+		em.comments = em.comments.merge(eo.comments)
+		em.redactions = unionRedactions(em.redactions, eo.redactions)
+		eo.mutex.RUnlock()
+		if err != nil {
+			return nil, errors.Annotate(err, ErrCannotMerge, errorMessages)
+		}
+	}
+	notifyChanges(changedValues(ls, em.values))
+	return em, nil
+}
+
+// String implements the Stringer interface.
 func (e *etc) String() string {
-	return fmt.Sprintf("%v", e.values)
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	values := e.redactedValues()
+	enc := newSMLEncoder()
+	if err := writeValues(enc, values, etcRoot, nil); err != nil {
+		// Keys set at runtime may be no valid tags.
+		return fmt.Sprintf("%v", values)
+	}
+	return strings.TrimSuffix(enc.bytes().String(), "\n")
+}
+
+// redactedValues returns the values with the redacted
+// ones replaced. Without redactions the values are
+// returned directly.
+func (e *etc) redactedValues() collections.KeyStringValueTree {
+	if len(e.redactions) == 0 {
+		return e.values
+	}
+	values := e.values.Copy()
+	var redacted [][]string
+	values.DoAllDeep(func(ks []string, v string) error {
+		if v != "" && e.isRedacted(ks) {
+			redacted = append(redacted, ks)
+		}
+		return nil
+	})
+	for _, ks := range redacted {
+		values.At(ks...).SetValue(redactedValue)
+	}
+	return values
+}
+
+// isRedacted checks if the full path or one of
+// its parents matches a redaction pattern.
+func (e *etc) isRedacted(fullPath []string) bool {
+	for i := 2; i <= len(fullPath); i++ {
+		for _, pattern := range e.redactions {
+			if matchGlob(strings.Split(pattern, "/"), fullPath[1:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rerootRedactions returns the redaction patterns for the
+// subconfiguration at the prefix. Patterns matching the prefix
+// or one of its parents redact the whole subconfiguration.
+func rerootRedactions(patterns []string, prefix []string) []string {
+	var rerooted []string
+	for _, pattern := range patterns {
+		residuals := [][]string{strings.Split(pattern, "/")}
+		for _, part := range prefix {
+			var next [][]string
+			for _, residual := range residuals {
+				next = append(next, deriveRedaction(residual, part)...)
+			}
+			residuals = next
+		}
+		for _, residual := range residuals {
+			if len(residual) == 0 {
+				residual = []string{"**"}
+			}
+			rerooted = unionRedactions(rerooted, []string{strings.Join(residual, "/")})
+		}
+	}
+	return rerooted
+}
+
+// deriveRedaction returns the remaining patterns after the first
+// part of a path has been matched. An empty pattern means that a
+// parent already matched.
+func deriveRedaction(pattern []string, part string) [][]string {
+	switch {
+	case len(pattern) == 0:
+		return [][]string{pattern}
+	case pattern[0] == "**":
+		return append([][]string{pattern}, deriveRedaction(pattern[1:], part)...)
+	}
+	if ok, _ := path.Match(pattern[0], part); ok {
+		return [][]string{pattern[1:]}
+	}
+	return nil
+}
+
+// unionRedactions returns the patterns of both lists
+// without duplicates.
+func unionRedactions(patterns, others []string) []string {
+	union := append([]string{}, patterns...)
+	for _, other := range others {
+		found := false
+		for _, pattern := range union {
+			if pattern == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			union = append(union, other)
+		}
+	}
+	return union
+}
+
+// fullPath creates the full path out of a string using the
+// passed separator and resolves it.
+func (e *etc) fullPath(path, sep string) []string {
+	return e.resolve(makeFullPath(path, sep))
+}
+
+// resolve replaces the parts of the full path by the
+// matching keys if the configuration is case-insensitive.
+func (e *etc) resolve(fullPath []string) []string {
+	if !e.caseInsensitive {
+		return fullPath
+	}
+	resolved := append([]string{}, fullPath...)
+	for i := 1; i < len(resolved); i++ {
+		children, err := e.values.At(resolved[:i]...).List()
+		if err != nil {
+			break
+		}
+		for _, child := range children {
+			if strings.EqualFold(child.Key, resolved[i]) {
+				resolved[i] = child.Key
+				break
+			}
+		}
+	}
+	return resolved
 }
 
 // valueAt retrieves and encapsulates the value
 // at a given path.
 func (e *etc) valueAt(path string) *value {
-	fullPath := makeFullPath(path)
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return &value{e, e.fullPath(path, e.separator)}
+}
+
+// lookup returns the changer at the given full path. If it
+// cannot be found the error names the first missing part.
+func (e *etc) lookup(fullPath []string) (collections.KeyStringValueChanger, error) {
+	fullPath = e.resolve(fullPath)
 	changer := e.values.At(fullPath...)
-	return &value{fullPath, changer}
+	if changer.Error() == nil {
+		return changer, nil
+	}
+	for i := 2; i < len(fullPath); i++ {
+		if e.values.At(fullPath[:i]...).Error() != nil {
+			return nil, errors.New(ErrInvalidPath, errorMessages, pathToString(fullPath), fullPath[i-1])
+		}
+	}
+	return nil, errors.New(ErrInvalidPath, errorMessages, pathToString(fullPath), fullPath[len(fullPath)-1])
+}
+
+// expand replaces the references to other values.
+func (e *etc) expand(sv string) (string, error) {
+	if e.expanded {
+		return sv, nil
+	}
+	return e.expandReferences(sv, nil)
+}
+
+// expandReferences replaces the references to other values
+// recursively. The chain contains the currently expanded
+// references to detect circles.
+func (e *etc) expandReferences(sv string, chain []string) (string, error) {
+	if !strings.Contains(sv, "${") {
+		return sv, nil
+	}
+	var buf bytes.Buffer
+	for {
+		start := strings.Index(sv, "${")
+		if start < 0 {
+			buf.WriteString(sv)
+			break
+		}
+		if start > 0 && sv[start-1] == '$' {
+			// Escaped reference.
+			buf.WriteString(sv[:start+2])
+			sv = sv[start+2:]
+			continue
+		}
+		end := strings.Index(sv[start:], "}")
+		if end < 0 {
+			buf.WriteString(sv)
+			break
+		}
+		path := sv[start+2 : start+end]
+		for i, ref := range chain {
+			if ref == path {
+				circle := strings.Join(append(chain[i:], path), " -> ")
+				return "", errors.New(ErrCircularReference, errorMessages, circle)
+			}
+		}
+		changer, err := e.lookup(e.fullPath(path, defaultSeparator))
+		if err != nil {
+			return "", err
+		}
+		ref, err := changer.Value()
+		if err != nil {
+			return "", err
+		}
+		if ref, err = e.expandReferences(ref, append(chain, path)); err != nil {
+			return "", err
+		}
+		buf.WriteString(sv[:start])
+		buf.WriteString(ref)
+		sv = sv[start+end+1:]
+	}
+	return strings.Replace(buf.String(), "$${", "${", -1), nil
 }
 
 // postProcess replaces templates formated [path||default]
@@ -314,27 +1258,252 @@ func (e *etc) postProcess() error {
 }
 
 //--------------------
-// CONTEXT
+// HELPERS
 //--------------------
 
-// NewContext returns a new context that carries a configuration.
-func NewContext(ctx context.Context, cfg Etc) context.Context {
-	return context.WithValue(ctx, etcKey, cfg)
+// mergeValues merges the source node at the source path into
+// the destination node at the destination path.
+func mergeValues(dst collections.KeyStringValueTree, dstPath []string, src collections.KeyStringValueTree, srcPath []string, lm ListMerging) error {
+	srcChanger := src.At(srcPath...)
+	srcChildren, err := srcChanger.List()
+	if err != nil {
+		return err
+	}
+	dstChanger := dst.Create(dstPath...)
+	dstChildren, err := dstChanger.List()
+	if err != nil {
+		return err
+	}
+	if len(srcChildren) == 0 {
+		// Source is a single value.
+		value, err := srcChanger.Value()
+		if err != nil {
+			return err
+		}
+		if len(dstChildren) > 0 {
+			if value == "" {
+				// Empty nodes don't override subconfigurations.
+				return nil
+			}
+			if err = removeChildren(dst, dstPath, dstChildren); err != nil {
+				return err
+			}
+		}
+		_, err = dstChanger.SetValue(value)
+		return err
+	}
+	// Source has children, so a value of the destination is dropped.
+	if _, err = dstChanger.SetValue(""); err != nil {
+		return err
+	}
+	offset := 0
+	if isList(srcChildren) && isList(dstChildren) {
+		switch lm {
+		case AppendLists:
+			offset = len(dstChildren)
+		default:
+			if err = removeChildren(dst, dstPath, dstChildren); err != nil {
+				return err
+			}
+		}
+	}
+	for i, child := range srcChildren {
+		dstKey := child.Key
+		if offset > 0 {
+			dstKey = strconv.Itoa(offset + i)
+		}
+		err = mergeValues(dst, appendPath(dstPath, dstKey), src, appendPath(srcPath, child.Key), lm)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// FromContext returns the configuration stored in ctx, if any.
-func FromContext(ctx context.Context) (Etc, bool) {
-	cfg, ok := ctx.Value(etcKey).(Etc)
-	return cfg, ok
+// writeValues writes the node at the path, its
+// children, and the comments with the encoder.
+func writeValues(enc *smlEncoder, tree collections.KeyStringValueTree, path []string, cs comments) error {
+	changer := tree.At(path...)
+	value, err := changer.Value()
+	if err != nil {
+		return err
+	}
+	children, err := changer.List()
+	if err != nil {
+		return err
+	}
+	key := strings.Join(path, "/")
+	for _, comment := range cs[key] {
+		enc.comment(comment)
+	}
+	if err = enc.openTag(path[len(path)-1]); err != nil {
+		return err
+	}
+	enc.text(value)
+	for _, child := range children {
+		if err = writeValues(enc, tree, appendPath(path, child.Key), cs); err != nil {
+			return err
+		}
+	}
+	for _, comment := range cs[key+"/"] {
+		enc.comment(comment)
+	}
+	enc.closeTag()
+	return nil
 }
 
-//--------------------
-// HELPERS
-//--------------------
+// isList checks if the children form a list.
+func isList(children []collections.KeyStringValue) bool {
+	if len(children) == 0 {
+		return false
+	}
+	for _, child := range children {
+		if _, err := strconv.Atoi(child.Key); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// removeChildren removes the children of the node at the path.
+func removeChildren(tree collections.KeyStringValueTree, path []string, children []collections.KeyStringValue) error {
+	for _, child := range children {
+		if err := tree.At(appendPath(path, child.Key)...).Remove(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendPath returns a new path out of the passed one and the key.
+func appendPath(path []string, key string) []string {
+	newPath := make([]string, len(path)+1)
+	copy(newPath, path)
+	newPath[len(path)] = key
+	return newPath
+}
+
+// matchGlob checks if the parts of a path match the parts
+// of a glob pattern.
+func matchGlob(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchGlob(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchGlob(pattern[1:], parts[1:])
+}
+
+// checkType checks if the value can be parsed as the type.
+func checkType(sv string, t Type) error {
+	var err error
+	switch t {
+	case TypeString:
+	case TypeBool:
+		sv, _ = (&boolValue{stringex.StringValuer(sv)}).Value()
+		_, err = strconv.ParseBool(sv)
+	case TypeInt:
+		_, err = strconv.Atoi(sv)
+	case TypeFloat64:
+		_, err = strconv.ParseFloat(sv, 64)
+	case TypeTime:
+		_, err = time.Parse(time.RFC3339, sv)
+	case TypeDuration:
+		_, err = time.ParseDuration(sv)
+	case TypeBytes:
+		_, err = parseBytes(sv)
+	default:
+		err = fmt.Errorf("unknown type")
+	}
+	return err
+}
+
+// byteUnits maps the lowercased units to their factors.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// parseBytes interprets a value like "1.5GiB" as number of bytes.
+func parseBytes(sv string) (int64, error) {
+	sv = strings.TrimSpace(sv)
+	split := strings.IndexFunc(sv, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split < 0 {
+		split = len(sv)
+	}
+	number, unit := sv[:split], strings.ToLower(strings.TrimSpace(sv[split:]))
+	if number == "" {
+		return 0, fmt.Errorf("missing number in %q", sv)
+	}
+	factor, ok := byteUnits[unit]
+	if !ok {
+		switch unit {
+		case "k", "m", "g", "t", "p":
+			return 0, fmt.Errorf("ambiguous unit %q, use SI or binary units", sv[split:])
+		}
+		return 0, fmt.Errorf("unknown unit %q", sv[split:])
+	}
+	fv, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+	bytes := math.Floor(fv*factor + 0.5)
+	if bytes >= 1<<63 {
+		return 0, fmt.Errorf("value %q is too large", sv)
+	}
+	return int64(bytes), nil
+}
+
+// valueToString returns the string representation of
+// a value to set.
+func valueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", value)
+}
 
-// makeFullPath creates the full path out of a string.
-func makeFullPath(path string) []string {
-	parts := stringex.SplitMap(path, "/", func(p string) (string, bool) {
+// makeFullPath creates the full path out of a string
+// using the passed separator.
+func makeFullPath(path, sep string) []string {
+	parts := stringex.SplitMap(path, sep, func(p string) (string, bool) {
 		if p == "" {
 			return "", false
 		}
@@ -12,11 +12,17 @@ package etc_test
 //--------------------
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/tideland/golib/audit"
 	"github.com/tideland/golib/etc"
@@ -78,6 +84,404 @@ func TestReadFile(t *testing.T) {
 	assert.ErrorMatch(err, `.* cannot read configuration file .*`)
 }
 
+// TestReadFileIncludes tests reading configuration files
+// including other files.
+func TestReadFileIncludes(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	tempDir := audit.NewTempDir(assert)
+	defer tempDir.Restore()
+	writeFile := func(name, content string) string {
+		filename := filepath.Join(tempDir.String(), name)
+		assert.Nil(os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(ioutil.WriteFile(filename, []byte(content), 0644))
+		return filename
+	}
+
+	mainFile := writeFile("main.etc", `{etc
+	{name service}
+	{db {#@include sub/db.etc#}}
+	{#@include sub/log.etc#}
+}`)
+	writeFile("sub/db.etc", "{etc {host localhost}{port 5432}{#@include user.etc#}}")
+	writeFile("sub/user.etc", "{etc {user admin}}")
+	writeFile("sub/log.etc", "{etc {log {level debug}}}")
+
+	cfg, err := etc.ReadFile(mainFile)
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "service")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsInt("db/port", 0), 5432)
+	assert.Equal(cfg.ValueAsString("db/user", "X"), "admin")
+	assert.Equal(cfg.ValueAsString("log/level", "X"), "debug")
+
+	// Circular includes.
+	cycle := writeFile("cycle/a.etc", "{etc {a 1}{#@include b.etc#}}")
+	writeFile("cycle/b.etc", "{etc {b 2}{#@include a.etc#}}")
+	_, err = etc.ReadFile(cycle)
+	assert.ErrorMatch(err, `.* circular include .*a.etc -> .*b.etc -> .*a.etc`)
+
+	// Missing includes and includes without file.
+	missing := writeFile("missing.etc", "{etc {#@include not-existing.etc#}}")
+	_, err = etc.ReadFile(missing)
+	assert.ErrorMatch(err, `.* cannot include ".*not-existing.etc": file cannot be opened: .*`)
+	cfg, err = etc.ReadString("{etc {#@include sub/db.etc#}{a 1}}")
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsInt("a", 0), 1)
+	var buf bytes.Buffer
+	assert.Nil(etc.Write(&buf, cfg))
+	assert.Substring("{# @include sub/db.etc #}", buf.String())
+
+	// Other comments stay comments, also when starting
+	// with "include".
+	cfg, err = etc.ReadString("{etc {# include sub/db.etc #}{a 1}}")
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsInt("a", 0), 1)
+	cfg, err = etc.ReadString("{etc {#include the defaults below#}{a 1}}")
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsInt("a", 0), 1)
+	prose := writeFile("prose.etc", "{etc {#include the defaults below#}{a 1}}")
+	cfg, err = etc.ReadFile(prose)
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsInt("a", 0), 1)
+	buf.Reset()
+	assert.Nil(etc.Write(&buf, cfg))
+	assert.Substring("{# include the defaults below #}", buf.String())
+}
+
+// TestReadStrict tests the strict reading of configurations.
+func TestReadStrict(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {db {host localhost}}{hosts {0 alpha}{1 beta}}{db {port 5432}}}"
+	cfg, err := etc.Read(strings.NewReader(source))
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsInt("db/port", 0), 5432)
+	cfg, err = etc.ReadStrict(strings.NewReader(source))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* illegal source format: .* duplicate configuration key "/etc/db"`)
+
+	source = "{etc\n\t{db\n\t\t{host localhost}\n\t\t{port 5432}\n\t\t{host db.example.com}\n\t}\n}"
+	cfg, err = etc.ReadStrict(strings.NewReader(source))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* node at line 5, column 3: .* duplicate configuration key "/etc/db/host"`)
+
+	// Same keys in different nodes and lists are fine.
+	source = "{etc {a {host x}}{b {host y}}{hosts {0 alpha}{1 beta}}}"
+	cfg, err = etc.ReadStrict(strings.NewReader(source))
+	assert.Nil(err)
+	svs, err := cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta"})
+}
+
+// TestReadJSON tests reading a configuration out of a JSON document.
+func TestReadJSON(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{
+		"Name": "service {1}",
+		"port": 8080,
+		"ratio": 0.75,
+		"debug": true,
+		"empty": null,
+		"db": {"host": "localhost", "timeout": "5s", "DB_Host": "db.local"},
+		"hosts": ["alpha", "beta", {"name": "gamma"}]
+	}`
+	cfg, err := etc.Read(etc.NewJSONReader(strings.NewReader(source)))
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "service {1}")
+	assert.Equal(cfg.ValueAsInt("port", 0), 8080)
+	assert.Equal(cfg.ValueAsFloat64("port", 0.0), 8080.0)
+	assert.Equal(cfg.ValueAsFloat64("ratio", 0.0), 0.75)
+	assert.Equal(cfg.ValueAsBool("debug", false), true)
+	assert.True(cfg.HasPath("empty"))
+	assert.Equal(cfg.ValueAsString("empty", "X"), "")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsString("db/db-host", "X"), "db.local")
+	assert.Equal(cfg.ValueAsDuration("db/timeout", 0), 5*time.Second)
+	assert.Equal(cfg.ValueAsString("hosts/0", "X"), "alpha")
+	assert.Equal(cfg.ValueAsString("hosts/1", "X"), "beta")
+	assert.Equal(cfg.ValueAsString("hosts/2/name", "X"), "gamma")
+
+	cfg, err = etc.Read(etc.NewJSONReader(strings.NewReader(`["a", "b"]`)))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* cannot convert JSON configuration source: document is no object`)
+
+	cfg, err = etc.Read(etc.NewJSONReader(strings.NewReader(`{"foo": 1,}`)))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* cannot convert JSON configuration source: invalid document: .*`)
+	assert.False(strings.Contains(err.Error(), "%!v"))
+
+	cfg, err = etc.Read(etc.NewJSONReader(strings.NewReader(`{"foo": [1, 2}`)))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* cannot convert JSON configuration source: invalid document: .*`)
+
+	cfg, err = etc.Read(etc.NewJSONReader(strings.NewReader(`{"foo bar": 1}`)))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* illegal configuration key "foo bar"`)
+}
+
+// TestReadYAML tests reading a configuration out of a YAML document.
+func TestReadYAML(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `---
+# Service configuration.
+Name: "service {1}"
+port: 8080   # HTTP
+ratio: 0.75
+debug: yes
+empty: ~
+quote: 'it''s # no comment'
+db:
+  host: localhost
+  timeout: 5s
+  max_conn: 25
+hosts:
+- alpha
+- name: gamma
+  port: 9090
+- - x
+  - y
+ports: [80, 443]
+limits: {cpu: 2, memory: 512M, max_load: 3}
+script: |
+  echo one
+  echo two
+text: >
+  folded
+  line
+`
+	cfg, err := etc.Read(etc.NewYAMLReader(strings.NewReader(source)))
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "service {1}")
+	assert.Equal(cfg.ValueAsInt("port", 0), 8080)
+	assert.Equal(cfg.ValueAsFloat64("ratio", 0.0), 0.75)
+	assert.Equal(cfg.ValueAsString("debug", "X"), "yes")
+	assert.True(cfg.HasPath("empty"))
+	assert.Equal(cfg.ValueAsString("empty", "X"), "")
+	assert.Equal(cfg.ValueAsString("quote", "X"), "it's # no comment")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsInt("db/max-conn", 0), 25)
+	assert.Equal(cfg.ValueAsDuration("db/timeout", 0), 5*time.Second)
+	assert.Equal(cfg.ValueAsString("hosts/0", "X"), "alpha")
+	assert.Equal(cfg.ValueAsString("hosts/1/name", "X"), "gamma")
+	assert.Equal(cfg.ValueAsInt("hosts/1/port", 0), 9090)
+	assert.Equal(cfg.ValueAsString("hosts/2/0", "X"), "x")
+	assert.Equal(cfg.ValueAsString("hosts/2/1", "X"), "y")
+	assert.Equal(cfg.ValueAsInt("ports/0", 0), 80)
+	assert.Equal(cfg.ValueAsInt("ports/1", 0), 443)
+	assert.Equal(cfg.ValueAsInt("limits/cpu", 0), 2)
+	assert.Equal(cfg.ValueAsString("limits/memory", "X"), "512M")
+	assert.Equal(cfg.ValueAsInt("limits/max-load", 0), 3)
+	assert.Equal(cfg.ValueAsString("script", "X"), "echo one\necho two")
+	assert.Equal(cfg.ValueAsString("text", "X"), "folded line")
+
+	// Empty documents lead to empty configurations.
+	cfg, err = etc.Read(etc.NewYAMLReader(strings.NewReader("")))
+	assert.Nil(err)
+	assert.False(cfg.HasPath("foo"))
+	cfg, err = etc.Read(etc.NewYAMLReader(strings.NewReader("---\n# Nothing here.\n")))
+	assert.Nil(err)
+	assert.False(cfg.HasPath("foo"))
+
+	// Unsupported or illegal documents.
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"- a\n- b\n", `.* line 1: document is no mapping`},
+		{"base: &base\n  a: 1\n", `.* line 1: anchors and aliases are not supported`},
+		{"other: *base\n", `.* line 1: anchors and aliases are not supported`},
+		{"a: !!str 1\n", `.* line 1: tags are not supported`},
+		{"a: 1\n  b: 2\n", `.* line 2: unexpected indentation`},
+		{"a: 1\n---\nb: 2\n", `.* line 2: multiple documents are not supported`},
+		{"a: [1, 2\n", `.* line 1: unterminated flow collection`},
+		{"just text\n", `.* line 1: expected mapping key`},
+	}
+	for _, test := range tests {
+		cfg, err = etc.Read(etc.NewYAMLReader(strings.NewReader(test.source)))
+		assert.Nil(cfg)
+		assert.ErrorMatch(err, test.err)
+	}
+
+	// Unreadable sources.
+	cfg, err = etc.Read(etc.NewYAMLReader(iotest.ErrReader(errors.New("broken"))))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* cannot convert YAML configuration source: unreadable document: broken`)
+}
+
+// TestReadTOML tests reading a configuration out of a TOML document.
+func TestReadTOML(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `# Service configuration.
+Name = "service {1}"
+port = 8_080   # HTTP
+mask = 0xff
+ratio = 0.75
+debug = true
+quote = 'C:\path # no comment'
+escaped = "tab\tand \u00e9"
+started = 1979-05-27 07:32:00Z
+day = 1979-05-27
+ports = [ 80, 443 ]
+matrix = [[1, 2], ["a"]]
+limits = { cpu = 2, memory.max = "512M" }
+script = """
+echo one
+echo two"""
+site.url = "https://example.com"
+
+[db]
+host = "localhost"
+"timeout" = "5s"
+max_conn = 25
+
+[db.pool]
+size = 10
+
+[[hosts]]
+name = "alpha"
+
+[[hosts]]
+name = "beta"
+tags = [
+  "a",  # first
+  "b",
+]
+`
+	cfg, err := etc.Read(etc.NewTOMLReader(strings.NewReader(source)))
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "service {1}")
+	assert.Equal(cfg.ValueAsInt("port", 0), 8080)
+	assert.Equal(cfg.ValueAsInt("mask", 0), 255)
+	assert.Equal(cfg.ValueAsFloat64("ratio", 0.0), 0.75)
+	assert.True(cfg.ValueAsBool("debug", false))
+	assert.Equal(cfg.ValueAsString("quote", "X"), `C:\path # no comment`)
+	assert.Equal(cfg.ValueAsString("escaped", "X"), "tab\tand \u00e9")
+	started := time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)
+	assert.Equal(cfg.ValueAsTime("started", time.RFC3339, time.Time{}), started)
+	assert.Equal(cfg.ValueAsString("day", "X"), "1979-05-27")
+	ivs, err := cfg.IntValuesAt("ports")
+	assert.Nil(err)
+	assert.Equal(ivs, []int{80, 443})
+	assert.Equal(cfg.ValueAsInt("matrix/0/1", 0), 2)
+	assert.Equal(cfg.ValueAsString("matrix/1/0", "X"), "a")
+	assert.Equal(cfg.ValueAsInt("limits/cpu", 0), 2)
+	assert.Equal(cfg.ValueAsString("limits/memory/max", "X"), "512M")
+	assert.Equal(cfg.ValueAsString("script", "X"), "echo one\necho two")
+	assert.Equal(cfg.ValueAsString("site/url", "X"), "https://example.com")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsInt("db/max-conn", 0), 25)
+	assert.Equal(cfg.ValueAsDuration("db/timeout", 0), 5*time.Second)
+	assert.Equal(cfg.ValueAsInt("db/pool/size", 0), 10)
+	assert.Equal(cfg.ValueAsString("hosts/0/name", "X"), "alpha")
+	assert.Equal(cfg.ValueAsString("hosts/1/name", "X"), "beta")
+	svs, err := cfg.ValuesAt("hosts/1/tags")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"a", "b"})
+
+	// Empty documents lead to empty configurations.
+	cfg, err = etc.Read(etc.NewTOMLReader(strings.NewReader("# Nothing here.\n")))
+	assert.Nil(err)
+	assert.False(cfg.HasPath("foo"))
+
+	// Illegal documents.
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"a = 1\na = 2\n", `.* line 2: key "a" is already defined`},
+		{"[a]\nb = 1\n[a]\n", `.* line 3: key "a" is already defined`},
+		{"a = 1\n[a.b]\n", `.* line 2: key "a" is already defined`},
+		{"a = [1, 2\n", `.* line 2: unterminated array`},
+		{"a = \"open\n", `.* line 1: unterminated string`},
+		{"a = yes\n", `.* line 1: invalid value "yes"`},
+		{"a = 1 b = 2\n", `.* line 1: unexpected characters after expression`},
+		{"[a\n", `.* line 1: missing "]" of table header`},
+		{"\"a b\" = 1\n", `.* illegal configuration key "a b"`},
+	}
+	for _, test := range tests {
+		cfg, err = etc.Read(etc.NewTOMLReader(strings.NewReader(test.source)))
+		assert.Nil(cfg)
+		assert.ErrorMatch(err, test.err)
+	}
+
+	// Unreadable sources.
+	cfg, err = etc.Read(etc.NewTOMLReader(iotest.ErrReader(errors.New("broken"))))
+	assert.Nil(cfg)
+	assert.ErrorMatch(err, `.* cannot convert TOML configuration source: unreadable document: broken`)
+}
+
+// TestWatcher tests the watching of configuration files.
+func TestWatcher(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	tempDir := audit.NewTempDir(assert)
+	defer tempDir.Restore()
+	etcFilename := tempDir.String() + "/watched.etc"
+	write := func(source string, modTime time.Time) {
+		err := ioutil.WriteFile(etcFilename, []byte(source), 0644)
+		assert.Nil(err)
+		err = os.Chtimes(etcFilename, modTime, modTime)
+		assert.Nil(err)
+	}
+	now := time.Now()
+	write("{etc {foo 1}}", now.Add(-time.Minute))
+
+	w, err := etc.NewWatcher(etcFilename, 10*time.Millisecond)
+	assert.Nil(err)
+	assert.Equal(w.Current().ValueAsInt("foo", 0), 1)
+
+	// Valid change.
+	write("{etc {foo 2}}", now)
+	select {
+	case cfg := <-w.Changes():
+		assert.Equal(cfg.ValueAsInt("foo", 0), 2)
+	case <-time.After(time.Second):
+		assert.Fail("no change received")
+	}
+	assert.Equal(w.Current().ValueAsInt("foo", 0), 2)
+
+	// Invalid change keeps the current configuration.
+	write("{etc {foo 3}x", now.Add(time.Minute))
+	select {
+	case err := <-w.Errors():
+		assert.ErrorMatch(err, `.* illegal source format: .*`)
+	case <-time.After(time.Second):
+		assert.Fail("no error received")
+	}
+	assert.Equal(w.Current().ValueAsInt("foo", 0), 2)
+
+	// Fixing the file is noticed even with the same
+	// modification time and size.
+	write("{etc {foo 3}}", now.Add(time.Minute))
+	select {
+	case cfg := <-w.Changes():
+		assert.Equal(cfg.ValueAsInt("foo", 0), 3)
+	case <-time.After(time.Second):
+		assert.Fail("no change received")
+	}
+	assert.Equal(w.Current().ValueAsInt("foo", 0), 3)
+
+	// Closing stops the watcher and closes the channels.
+	err = w.Close()
+	assert.Nil(err)
+	_, ok := <-w.Changes()
+	assert.False(ok)
+	_, ok = <-w.Errors()
+	assert.False(ok)
+	err = w.Close()
+	assert.Nil(err)
+
+	// Illegal arguments.
+	_, err = etc.NewWatcher(etcFilename+"-missing", time.Second)
+	assert.ErrorMatch(err, `.* cannot read configuration file .*`)
+	_, err = etc.NewWatcher(etcFilename, 0)
+	assert.ErrorMatch(err, `.* illegal poll time 0s`)
+}
+
 // TestTemplates tests the substitution of templates.
 func TestTemplates(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -134,6 +538,426 @@ func TestTemplates(t *testing.T) {
 	assert.Equal(vs, "[$]")
 }
 
+// TestCaseInsensitive tests the case-insensitive finding of nodes.
+func TestCaseInsensitive(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {DB {Host localhost}{port 5432}}{Hosts {0 alpha}{1 beta}}}"
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	assert.False(cfg.HasPath("DB/Host"))
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "X")
+
+	assert.Nil(cfg.SetCaseInsensitive(true))
+	assert.True(cfg.HasPath("DB/Host"))
+	assert.Equal(cfg.ValueAsString("DB/Host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsInt("db/PORT", 0), 5432)
+	svs, err := cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta"})
+
+	// Setting uses the existing keys.
+	assert.Nil(cfg.Set("db/host", "db.example.com"))
+	keys, err := cfg.Keys("db")
+	assert.Nil(err)
+	assert.Equal(keys, []string{"Host", "port"})
+	assert.Equal(cfg.ValueAsString("DB/Host", "X"), "db.example.com")
+
+	// Subconfigurations inherit the setting.
+	db, err := cfg.Split("db")
+	assert.Nil(err)
+	assert.Equal(db.ValueAsString("HOST", "X"), "db.example.com")
+
+	// Keys only differing in case conflict.
+	source = "{etc {db {Host a}{host b}}}"
+	cfg, err = etc.ReadString(source)
+	assert.Nil(err)
+	err = cfg.SetCaseInsensitive(true)
+	assert.ErrorMatch(err, `.* keys "Host" and "host" at "/etc/db" only differ in case`)
+	assert.Equal(cfg.ValueAsString("DB/Host", "X"), "b")
+}
+
+// TestWrite tests writing a configuration as SML.
+func TestWrite(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc {name  service ^{1^}  }{db {host localhost}{port 5432}}
+	{hosts {0 alpha}{1 beta}}{empty}}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	cfg, err = cfg.Apply(etc.Application{"db/user": " admin "})
+	assert.Nil(err)
+
+	var first bytes.Buffer
+	err = etc.Write(&first, cfg)
+	assert.Nil(err)
+	assert.Equal(first.String(), `{etc
+	{name service ^{1^}}
+	{db
+		{host localhost}
+		{port 5432}
+		{user admin}
+	}
+	{hosts
+		{0 alpha}
+		{1 beta}
+	}
+	{empty}
+}
+`)
+
+	// Reading and writing again leads to the same output.
+	cfg, err = etc.Read(bytes.NewReader(first.Bytes()))
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "service {1}")
+	assert.Equal(cfg.ValueAsString("db/user", "X"), "admin")
+	var second bytes.Buffer
+	err = etc.Write(&second, cfg)
+	assert.Nil(err)
+	assert.Equal(second.Bytes(), first.Bytes())
+
+	// Subconfigurations are written with a new root.
+	db, err := cfg.Split("db")
+	assert.Nil(err)
+	var third bytes.Buffer
+	err = etc.Write(&third, db)
+	assert.Nil(err)
+	assert.Equal(third.String(), "{etc\n\t{host localhost}\n\t{port 5432}\n\t{user admin}\n}\n")
+}
+
+// TestComments tests that comments are kept when reading
+// and writing configurations.
+func TestComments(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{# The name of the service. #}
+	{name service}
+	{# The database. #}
+	{db
+		{# Database host and port. #}
+		{host localhost}
+		{port 5432}
+		{# End of database. #}
+	}
+}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	assert.Nil(cfg.Set("db/port", "6543"))
+	assert.Nil(cfg.Set("db/user", "admin"))
+
+	var first bytes.Buffer
+	err = etc.Write(&first, cfg)
+	assert.Nil(err)
+	assert.Equal(first.String(), `{etc
+	{# The name of the service. #}
+	{name service}
+	{# The database. #}
+	{db
+		{# Database host and port. #}
+		{host localhost}
+		{port 6543}
+		{user admin}
+		{# End of database. #}
+	}
+}
+`)
+
+	// Reading and writing again leads to the same output.
+	cfg, err = etc.Read(bytes.NewReader(first.Bytes()))
+	assert.Nil(err)
+	var second bytes.Buffer
+	err = etc.Write(&second, cfg)
+	assert.Nil(err)
+	assert.Equal(second.Bytes(), first.Bytes())
+
+	// Subconfigurations keep their comments.
+	db, err := cfg.Split("db")
+	assert.Nil(err)
+	var third bytes.Buffer
+	err = etc.Write(&third, db)
+	assert.Nil(err)
+	assert.Equal(third.String(), "{etc\n\t{# Database host and port. #}\n\t{host localhost}\n\t{port 6543}\n\t{user admin}\n\t{# End of database. #}\n}\n")
+
+	// The written subconfiguration can be read again.
+	db, err = etc.Read(bytes.NewReader(third.Bytes()))
+	assert.Nil(err)
+	assert.Equal(db.ValueAsString("host", "X"), "localhost")
+	var fourth bytes.Buffer
+	err = etc.Write(&fourth, db)
+	assert.Nil(err)
+	assert.Equal(fourth.Bytes(), third.Bytes())
+}
+
+// TestStringAndJSON tests the textual and the JSON output.
+func TestStringAndJSON(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc {name service "one"}{db {host localhost}{password secret}}
+	{hosts {0 alpha}{1 {name beta}{port 9090}}}{empty}}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	assert.Nil(cfg.Redact("db/password"))
+
+	assert.Equal(cfg.String(), `{etc
+	{name service "one"}
+	{db
+		{host localhost}
+		{password ***}
+	}
+	{hosts
+		{0 alpha}
+		{1
+			{name beta}
+			{port 9090}
+		}
+	}
+	{empty}
+}`)
+	data, err := json.Marshal(cfg)
+	assert.Nil(err)
+	assert.Equal(string(data), `{"name":"service \"one\"","db":{"host":"localhost","password":"***"},`+
+		`"hosts":["alpha",{"name":"beta","port":"9090"}],"empty":[]}`)
+
+	// Reading the JSON leads to the same configuration.
+	jcfg, err := etc.Read(etc.NewJSONReader(bytes.NewReader(data)))
+	assert.Nil(err)
+	assert.Equal(jcfg.String(), cfg.String())
+	// Only the redacted raw value differs.
+	assert.Equal(jcfg.Diff(cfg), []etc.Change{
+		{Path: "db/password", Kind: etc.ChangeModified, Old: "***", New: "***"},
+	})
+
+	// Reading JSON and writing it again keeps empty lists.
+	jsource := `{"name":"service","ports":["80","443"],"hosts":[],"db":{"tags":[]}}`
+	jcfg, err = etc.Read(etc.NewJSONReader(strings.NewReader(jsource)))
+	assert.Nil(err)
+	data, err = json.Marshal(jcfg)
+	assert.Nil(err)
+	assert.Equal(string(data), jsource)
+	hosts, err := jcfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Length(hosts, 0)
+
+	// Empty configurations.
+	cfg, err = etc.ReadString("{etc}")
+	assert.Nil(err)
+	assert.Equal(cfg.String(), "{etc}")
+	data, err = json.Marshal(cfg)
+	assert.Nil(err)
+	assert.Equal(string(data), "{}")
+}
+
+// TestExpand tests the expansion of references to other values.
+func TestExpand(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{logdir /var/log/app}
+	{errlog $^{logdir^}/error.log}
+	{nested $^{errlog^}.1}
+	{literal $$^{logdir^}}
+	{unterminated $^{logdir}
+	{missing $^{unknown^}/x}
+	{ports {0 $^{port^}}{1 8443}}
+	{port 8080}
+	{circle {a $^{circle/b^}}{b $^{circle/c^}}{c $^{circle/a^}}}
+	}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	// Lazy expansion.
+	assert.Equal(cfg.ValueAsString("errlog", "X"), "/var/log/app/error.log")
+	assert.Equal(cfg.ValueAsString("nested", "X"), "/var/log/app/error.log.1")
+	assert.Equal(cfg.ValueAsString("literal", "X"), "${logdir}")
+	assert.Equal(cfg.ValueAsString("unterminated", "X"), "${logdir")
+	assert.Equal(cfg.ValueAsString("missing", "X"), "X")
+	assert.Equal(cfg.ValueAsString("circle/a", "X"), "X")
+	ivs, err := cfg.IntValuesAt("ports")
+	assert.Nil(err)
+	assert.Equal(ivs, []int{8080, 8443})
+	_, err = cfg.ValuesAt("circle/a")
+	assert.ErrorMatch(err, `.* circular reference circle/b -> circle/c -> circle/a -> circle/b`)
+
+	// Eager expansion.
+	_, err = cfg.Expand()
+	assert.ErrorMatch(err, `.* cannot expand configuration: .*`)
+	cfg, err = cfg.Apply(etc.Application{
+		"missing":  "none",
+		"circle/c": "end",
+	})
+	assert.Nil(err)
+	ecfg, err := cfg.Expand()
+	assert.Nil(err)
+	assert.Equal(ecfg.ValueAsString("circle/a", "X"), "end")
+	assert.Equal(ecfg.ValueAsString("literal", "X"), "${logdir}")
+	appl, err := ecfg.Dump()
+	assert.Nil(err)
+	assert.Equal(appl["errlog"], "/var/log/app/error.log")
+	assert.Equal(appl["literal"], "${logdir}")
+	appl, err = cfg.Dump()
+	assert.Nil(err)
+	assert.Equal(appl["errlog"], "${logdir}/error.log")
+}
+
+// TestMatch tests the matching of paths with globs.
+func TestMatch(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{timeout 1s}
+	{service
+		{b {timeout 2s}{url http://b}}
+		{a {timeout 3s}{db {timeout 4s}}}
+	}
+	{hosts {0 alpha}{1 beta}}
+	}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	tests := []struct {
+		glob    string
+		matches []string
+	}{
+		{"service/*/timeout", []string{"service/a/timeout", "service/b/timeout"}},
+		{"service/**/timeout", []string{"service/a/db/timeout", "service/a/timeout", "service/b/timeout"}},
+		{"**/timeout", []string{"service/a/db/timeout", "service/a/timeout", "service/b/timeout", "timeout"}},
+		{"service/?/url", []string{"service/b/url"}},
+		{"hosts/*", []string{"hosts/0", "hosts/1"}},
+		{"service/*", []string{}},
+		{"unknown/**", []string{}},
+	}
+	for _, test := range tests {
+		matches, err := cfg.Match(test.glob)
+		assert.Nil(err)
+		assert.Equal(matches, test.matches, test.glob)
+	}
+
+	// Separator is used for globs and results.
+	assert.Nil(cfg.SetPathSeparator("."))
+	matches, err := cfg.Match("service.*.timeout")
+	assert.Nil(err)
+	assert.Equal(matches, []string{"service.a.timeout", "service.b.timeout"})
+	assert.Equal(cfg.ValueAsDuration(matches[0], 0), 3*time.Second)
+
+	_, err = cfg.Match("service.[")
+	assert.ErrorMatch(err, `.* illegal pattern "service.\[": syntax error in pattern`)
+
+	// Redactions can use globs too.
+	assert.Nil(cfg.Redact("**/url"))
+	assert.False(strings.Contains(cfg.String(), "http://b"))
+}
+
+// TestRedact tests the redaction of sensitive values.
+func TestRedact(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {db {host localhost}{password secret}}{api {key abc}{token xyz}}{tls {cert {file a.pem}{pass b}}}}"
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	err = cfg.Redact("db/password", "api/*", "tls")
+	assert.Nil(err)
+
+	var buf bytes.Buffer
+	err = etc.Write(&buf, cfg)
+	assert.Nil(err)
+	assert.Equal(buf.String(), `{etc
+	{db
+		{host localhost}
+		{password ***}
+	}
+	{api
+		{key ***}
+		{token ***}
+	}
+	{tls
+		{cert
+			{file ***}
+			{pass ***}
+		}
+	}
+}
+`)
+	assert.False(strings.Contains(cfg.String(), "secret"))
+	var walked []string
+	err = cfg.Walk(func(path, value string) error {
+		walked = append(walked, value)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(walked, []string{"localhost", "***", "***", "***", "***", "***"})
+
+	// Real values are still accessible.
+	assert.Equal(cfg.ValueAsString("db/password", "X"), "secret")
+	assert.Equal(cfg.ValueAsString("api/key", "X"), "abc")
+	assert.Equal(cfg.ValueAsString("tls/cert/pass", "X"), "b")
+	appl, err := cfg.Dump()
+	assert.Nil(err)
+	assert.Equal(appl["db/password"], "secret")
+
+	// Applied configurations keep the redactions.
+	acfg, err := cfg.Apply(etc.Application{"db/password": "other"})
+	assert.Nil(err)
+	assert.Equal(acfg.ValueAsString("db/password", "X"), "other")
+	assert.False(strings.Contains(acfg.String(), "other"))
+
+	err = cfg.Redact("[")
+	assert.ErrorMatch(err, `.* illegal pattern "\[": .*`)
+}
+
+// TestRedactDerived tests that configurations derived from
+// redacted ones don't leak the redacted values.
+func TestRedactDerived(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {db {host localhost}{password secret}}{api {v1 {key abc}{name foo}}}{token t}}"
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	err = cfg.Redact("db/password", "**/key", "api/v2")
+	assert.Nil(err)
+
+	// Expanding.
+	ecfg, err := cfg.Expand()
+	assert.Nil(err)
+	assert.False(strings.Contains(ecfg.String(), "secret"))
+	assert.Equal(ecfg.ValueAsString("db/password", "X"), "secret")
+
+	// Splitting.
+	dbcfg, err := cfg.Split("db")
+	assert.Nil(err)
+	assert.Substring("{password ***}", dbcfg.String())
+	assert.Substring("{host localhost}", dbcfg.String())
+	var buf bytes.Buffer
+	err = etc.Write(&buf, dbcfg)
+	assert.Nil(err)
+	assert.False(strings.Contains(buf.String(), "secret"))
+	jsonb, err := json.Marshal(dbcfg)
+	assert.Nil(err)
+	assert.False(strings.Contains(string(jsonb), "secret"))
+	v1cfg, err := cfg.Split("api/v1")
+	assert.Nil(err)
+	assert.Substring("{key ***}", v1cfg.String())
+	assert.Substring("{name foo}", v1cfg.String())
+	assert.Nil(cfg.Redact("api"))
+	apicfg, err := cfg.Split("api")
+	assert.Nil(err)
+	assert.Substring("{name ***}", apicfg.String())
+	assert.False(strings.Contains(apicfg.String(), "foo"))
+
+	// Merging.
+	other, err := etc.ReadString("{etc {token t}}")
+	assert.Nil(err)
+	assert.Nil(other.Redact("token"))
+	plain, err := etc.ReadString("{etc {name plain}}")
+	assert.Nil(err)
+	mcfg, err := plain.Merge(etc.ReplaceLists, other)
+	assert.Nil(err)
+	assert.Substring("{token ***}", mcfg.String())
+	assert.Substring("{name plain}", mcfg.String())
+	assert.Equal(mcfg.ValueAsString("token", "X"), "t")
+}
+
 // TestHasPath tests the checking of paths.
 func TestHasPath(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -204,6 +1028,393 @@ func TestGetFail(t *testing.T) {
 	assert.Equal(vi, 12345)
 }
 
+// TestValueAsBool tests the different spellings of bool values.
+func TestValueAsBool(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	cfg, err := etc.Read(strings.NewReader("{etc}"))
+	assert.Nil(err)
+	cfg, err = cfg.Apply(etc.Application{
+		"a": "true", "b": "YES", "c": "On", "d": "1", "e": "  yes\t",
+		"f": "false", "g": "no", "h": "OFF", "i": "0", "j": " Off ",
+		"k": "T", "l": "nope", "m": "",
+	})
+	assert.Nil(err)
+
+	for _, path := range []string{"a", "b", "c", "d", "e", "k"} {
+		assert.True(cfg.ValueAsBool(path, false), path)
+	}
+	for _, path := range []string{"f", "g", "h", "i", "j"} {
+		assert.False(cfg.ValueAsBool(path, true), path)
+	}
+	// Invalid values lead to the default value.
+	for _, path := range []string{"l", "m", "missing"} {
+		assert.True(cfg.ValueAsBool(path, true), path)
+		assert.False(cfg.ValueAsBool(path, false), path)
+	}
+}
+
+// TestValueAsBytes tests the retrieval of byte quantities.
+func TestValueAsBytes(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{plain 1024}
+	{b 512B}
+	{kb 256KB}
+	{mb 256 MB}
+	{gb 1.5GB}
+	{kib 4KiB}
+	{mib 256mib}
+	{gib 1.5GiB}
+	{tib 2TiB}
+	{largest 8191PiB}
+	{overflow 8192PiB}
+	{ambiguous 10M}
+	{unknown 10XB}
+	{invalid 1.2.3MB}
+	{negative -1KB}
+	}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	assert.Equal(cfg.ValueAsBytes("plain", 0), int64(1024))
+	assert.Equal(cfg.ValueAsBytes("b", 0), int64(512))
+	assert.Equal(cfg.ValueAsBytes("kb", 0), int64(256000))
+	assert.Equal(cfg.ValueAsBytes("mb", 0), int64(256000000))
+	assert.Equal(cfg.ValueAsBytes("gb", 0), int64(1500000000))
+	assert.Equal(cfg.ValueAsBytes("kib", 0), int64(4096))
+	assert.Equal(cfg.ValueAsBytes("mib", 0), int64(256*1024*1024))
+	assert.Equal(cfg.ValueAsBytes("gib", 0), int64(1536*1024*1024))
+	assert.Equal(cfg.ValueAsBytes("tib", 0), int64(2*1024*1024*1024*1024))
+	assert.Equal(cfg.ValueAsBytes("largest", 0), int64(8191*1024*1024*1024*1024*1024))
+	for _, path := range []string{"overflow", "ambiguous", "unknown", "invalid", "negative", "missing"} {
+		assert.Equal(cfg.ValueAsBytes(path, 42), int64(42), path)
+	}
+
+	err = cfg.ValidateTypes(map[string]etc.Type{
+		"ambiguous": etc.TypeBytes,
+		"gib":       etc.TypeBytes,
+		"unknown":   etc.TypeBytes,
+	})
+	assert.ErrorMatch(err, `.* value "10M" at "ambiguous" is no bytes: ambiguous unit "M", use SI or binary units\n.* value "10XB" at "unknown" is no bytes: unknown unit "XB"`)
+}
+
+// TestValuesAt tests the retrieval of multiple values.
+func TestValuesAt(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{hosts {0 alpha}{1 beta}{2 gamma}}
+	{ports {0 80}{1 443}}
+	{ratios {a 0.5}{b 1.5}}
+	{single 42}
+	{empty}
+	}`
+	cfg, err := etc.Read(strings.NewReader(source))
+	assert.Nil(err)
+
+	svs, err := cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta", "gamma"})
+	ivs, err := cfg.IntValuesAt("ports")
+	assert.Nil(err)
+	assert.Equal(ivs, []int{80, 443})
+	fvs, err := cfg.Float64ValuesAt("ratios")
+	assert.Nil(err)
+	assert.Equal(fvs, []float64{0.5, 1.5})
+
+	// Single and empty values.
+	svs, err = cfg.ValuesAt("single")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"42"})
+	ivs, err = cfg.IntValuesAt("single")
+	assert.Nil(err)
+	assert.Equal(ivs, []int{42})
+	svs, err = cfg.ValuesAt("empty")
+	assert.Nil(err)
+	assert.Length(svs, 0)
+
+	// Errors.
+	_, err = cfg.ValuesAt("missing")
+	assert.ErrorMatch(err, `.* invalid configuration path "/etc/missing", part "missing" not found`)
+	assert.True(etc.IsInvalidPathError(err))
+	_, err = cfg.IntValuesAt("hosts")
+	assert.ErrorMatch(err, `.* value "alpha" at "hosts" is no int: .*`)
+	_, err = cfg.Float64ValuesAt("hosts")
+	assert.ErrorMatch(err, `.* value "alpha" at "hosts" is no float64: .*`)
+}
+
+// TestDefaultValues tests the default values for missing
+// and for present but empty values.
+func TestDefaultValues(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {empty}{invalid foo}}"
+	cfg, err := etc.Read(strings.NewReader(source))
+	assert.Nil(err)
+	now := time.Now()
+
+	// Missing path.
+	assert.False(cfg.HasPath("missing"))
+	assert.Equal(cfg.ValueAsString("missing", "foo"), "foo")
+	assert.Equal(cfg.ValueAsBool("missing", true), true)
+	assert.Equal(cfg.ValueAsInt("missing", 42), 42)
+	assert.Equal(cfg.ValueAsFloat64("missing", 47.11), 47.11)
+	assert.Equal(cfg.ValueAsTime("missing", time.RFC3339, now), now)
+	assert.Equal(cfg.ValueAsDuration("missing", time.Second), time.Second)
+
+	// Present but empty value.
+	assert.True(cfg.HasPath("empty"))
+	assert.Equal(cfg.ValueAsString("empty", "foo"), "")
+	assert.Equal(cfg.ValueAsBool("empty", true), true)
+	assert.Equal(cfg.ValueAsInt("empty", 42), 42)
+	assert.Equal(cfg.ValueAsFloat64("empty", 47.11), 47.11)
+	assert.Equal(cfg.ValueAsTime("empty", time.RFC3339, now), now)
+	assert.Equal(cfg.ValueAsDuration("empty", time.Second), time.Second)
+
+	// Present but unparsable value.
+	assert.True(cfg.HasPath("invalid"))
+	assert.Equal(cfg.ValueAsString("invalid", "bar"), "foo")
+	assert.Equal(cfg.ValueAsBool("invalid", true), true)
+	assert.Equal(cfg.ValueAsInt("invalid", 42), 42)
+	assert.Equal(cfg.ValueAsFloat64("invalid", 47.11), 47.11)
+	assert.Equal(cfg.ValueAsTime("invalid", time.RFC3339, now), now)
+	assert.Equal(cfg.ValueAsDuration("invalid", time.Second), time.Second)
+}
+
+// TestPathSeparator tests the changing of the path separator.
+func TestPathSeparator(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {service {db {host localhost}{ports {0 5432}{1 5433}}}}}"
+	cfg, err := etc.Read(strings.NewReader(source))
+	assert.Nil(err)
+
+	assert.Equal(cfg.ValueAsString("service/db/host", "X"), "localhost")
+	err = cfg.SetPathSeparator(".")
+	assert.Nil(err)
+	assert.True(cfg.HasPath("service.db.host"))
+	assert.Equal(cfg.ValueAsString("service.db.host", "X"), "localhost")
+	assert.Equal(cfg.ValueAsString("service/db/host", "X"), "X")
+	ivs, err := cfg.IntValuesAt("service.db.ports")
+	assert.Nil(err)
+	assert.Equal(ivs, []int{5432, 5433})
+
+	// Missing parts are named in the error.
+	_, err = cfg.ValuesAt("service.cache.host")
+	assert.ErrorMatch(err, `.* invalid configuration path "/etc/service/cache/host", part "cache" not found`)
+	assert.True(etc.IsInvalidPathError(err))
+
+	// Subconfigurations inherit the separator, applications use slashes.
+	db, err := cfg.Split("service.db")
+	assert.Nil(err)
+	assert.Equal(db.ValueAsInt("ports.1", 0), 5433)
+	cfg, err = cfg.Apply(etc.Application{"service/db/user": "admin"})
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("service.db.user", "X"), "admin")
+
+	err = cfg.SetPathSeparator("")
+	assert.ErrorMatch(err, `.* illegal path separator ""`)
+}
+
+// TestValidate tests the validation of configurations.
+func TestValidate(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := `{etc
+	{name service}
+	{debug yes}
+	{db {host localhost}{port 5432}{timeout 5s}}
+	{started 2016-11-23T12:30:00Z}
+	}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	err = cfg.Validate([]string{"name", "db/host", "db/port"})
+	assert.Nil(err)
+	err = cfg.Validate([]string{"name", "db/user", "cache/host"})
+	assert.ErrorMatch(err, `.* invalid configuration: .* invalid configuration path "/etc/db/user", part "user" not found\n.* invalid configuration path "/etc/cache/host", part "cache" not found`)
+	assert.True(etc.IsInvalidConfigurationError(err))
+	assert.True(etc.IsInvalidPathError(err))
+
+	err = cfg.ValidateTypes(map[string]etc.Type{
+		"name":       etc.TypeString,
+		"debug":      etc.TypeBool,
+		"db/port":    etc.TypeInt,
+		"db/timeout": etc.TypeDuration,
+		"started":    etc.TypeTime,
+	})
+	assert.Nil(err)
+	err = cfg.ValidateTypes(map[string]etc.Type{
+		"name":       etc.TypeInt,
+		"db/host":    etc.TypeFloat64,
+		"db/timeout": etc.TypeDuration,
+		"db/user":    etc.TypeString,
+	})
+	assert.ErrorMatch(err, `.* invalid configuration: .* value "localhost" at "db/host" is no float64: .*\n.* part "user" not found\n.* value "service" at "name" is no int: .*`)
+}
+
+// TestUnmarshal tests the unmarshalling into structs.
+func TestUnmarshal(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	type DB struct {
+		Host    string        `etc:"host,required"`
+		Port    uint16        `etc:"port"`
+		Timeout time.Duration `etc:"timeout"`
+	}
+	type Service struct {
+		Name    string
+		Debug   bool
+		Ratio   float64
+		Workers int       `etc:"pool/workers"`
+		Started time.Time `etc:"started"`
+		Hosts   []string  `etc:"hosts"`
+		Ports   []int     `etc:"ports"`
+		DB      DB        `etc:"db"`
+		Missing string    `etc:"missing"`
+		Skipped string    `etc:"-"`
+		hidden  string
+	}
+	source := `{etc
+	{name service}
+	{debug yes}
+	{ratio 0.75}
+	{pool {workers 8}}
+	{started 2016-11-23T12:30:00Z}
+	{hosts {0 alpha}{1 beta}}
+	{ports {0 80}{1 443}}
+	{db {host localhost}{port 5432}{timeout 5s}}
+	{skipped foo}
+	}`
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	svc := Service{Missing: "default"}
+	err = cfg.Unmarshal(&svc)
+	assert.Nil(err)
+	assert.Equal(svc.Name, "service")
+	assert.Equal(svc.Debug, true)
+	assert.Equal(svc.Ratio, 0.75)
+	assert.Equal(svc.Workers, 8)
+	assert.Equal(svc.Started, time.Date(2016, time.November, 23, 12, 30, 0, 0, time.UTC))
+	assert.Equal(svc.Hosts, []string{"alpha", "beta"})
+	assert.Equal(svc.Ports, []int{80, 443})
+	assert.Equal(svc.DB, DB{"localhost", 5432, 5 * time.Second})
+	assert.Equal(svc.Missing, "default")
+	assert.Equal(svc.Skipped, "")
+	assert.Equal(svc.hidden, "")
+
+	// Errors.
+	var db DB
+	err = cfg.Unmarshal(&db)
+	assert.ErrorMatch(err, `.* cannot unmarshal configuration: .* part "host" not found`)
+	err = cfg.Unmarshal(db)
+	assert.ErrorMatch(err, `.* illegal unmarshal target: no pointer to a struct`)
+	var invalid struct {
+		Name int
+	}
+	err = cfg.Unmarshal(&invalid)
+	assert.ErrorMatch(err, `.* value "service" at "/etc/name" is no int: .*`)
+
+	// Missing nested struct with required field.
+	var nested struct {
+		Name  string
+		Cache DB `etc:"cache"`
+	}
+	err = cfg.Unmarshal(&nested)
+	assert.ErrorMatch(err, `.* cannot unmarshal configuration: .* part "cache" not found`)
+
+	// Scalar as slice.
+	var single struct {
+		Hosts []string `etc:"name"`
+		Ports []int    `etc:"pool/workers"`
+	}
+	err = cfg.Unmarshal(&single)
+	assert.Nil(err)
+	assert.Equal(single.Hosts, []string{"service"})
+	assert.Equal(single.Ports, []int{8})
+}
+
+// TestSet tests the setting of values.
+func TestSet(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	cfg, err := etc.ReadString("{etc {a 1}{sub {b 2}}}")
+	assert.Nil(err)
+	now := time.Date(2016, time.November, 23, 12, 30, 0, 0, time.UTC)
+
+	assert.Nil(cfg.Set("a", 42))
+	assert.Nil(cfg.Set("new/int", -12345))
+	assert.Nil(cfg.Set("new/duration", 90*time.Second))
+	assert.Nil(cfg.Set("new/bool", true))
+	assert.Nil(cfg.Set("new/float", 47.11))
+	assert.Nil(cfg.Set("new/time", now))
+	assert.Nil(cfg.Set("new/string", "Hello"))
+
+	assert.Equal(cfg.ValueAsInt("a", 0), 42)
+	assert.Equal(cfg.ValueAsInt("new/int", 0), -12345)
+	assert.Equal(cfg.ValueAsDuration("new/duration", 0), 90*time.Second)
+	assert.Equal(cfg.ValueAsString("new/duration", ""), "1m30s")
+	assert.Equal(cfg.ValueAsBool("new/bool", false), true)
+	assert.Equal(cfg.ValueAsFloat64("new/float", 0.0), 47.11)
+	assert.Equal(cfg.ValueAsTime("new/time", time.RFC3339, time.Time{}), now)
+	assert.Equal(cfg.ValueAsString("new/string", ""), "Hello")
+	assert.Equal(cfg.ValueAsInt("sub/b", 0), 2)
+
+	err = cfg.Set("sub", "foo")
+	assert.ErrorMatch(err, `.* cannot set value at "/etc/sub": path contains a subconfiguration`)
+	err = cfg.Set("a/b", "foo")
+	assert.ErrorMatch(err, `.* cannot set value at "/etc/a/b": path contains a value`)
+	err = cfg.Set("", "foo")
+	assert.ErrorMatch(err, `.* cannot set value at "/etc": path is the root`)
+	assert.Equal(cfg.ValueAsInt("sub/b", 0), 2)
+	assert.Equal(cfg.ValueAsInt("a", 0), 42)
+}
+
+// TestKeysAndWalk tests the iteration over keys and values.
+func TestKeysAndWalk(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {name service}{db {host localhost}{port 5432}}{empty}{hosts {0 alpha}{1 beta}}}"
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+
+	keys, err := cfg.Keys("")
+	assert.Nil(err)
+	assert.Equal(keys, []string{"name", "db", "empty", "hosts"})
+	keys, err = cfg.Keys("db")
+	assert.Nil(err)
+	assert.Equal(keys, []string{"host", "port"})
+	keys, err = cfg.Keys("name")
+	assert.Nil(err)
+	assert.Length(keys, 0)
+	_, err = cfg.Keys("missing")
+	assert.True(etc.IsInvalidPathError(err))
+
+	var walked []string
+	err = cfg.Walk(func(path, value string) error {
+		walked = append(walked, path+"="+value)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(walked, []string{"name=service", "db/host=localhost", "db/port=5432", "empty=", "hosts/0=alpha", "hosts/1=beta"})
+
+	// Walking uses the separator and stops at the first error.
+	assert.Nil(cfg.SetPathSeparator("."))
+	walked = nil
+	err = cfg.Walk(func(path, value string) error {
+		if path == "db.port" {
+			return errors.New("stop")
+		}
+		walked = append(walked, cfg.ValueAsString(path, "X"))
+		return nil
+	})
+	assert.ErrorMatch(err, "stop")
+	assert.Equal(walked, []string{"service", "localhost"})
+}
+
 // TestSplit tests the splitting of configurations.
 func TestSplit(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -239,6 +1450,51 @@ func TestSplit(t *testing.T) {
 	assert.Equal(va, "Foo")
 	vb = subcfg.ValueAsString("b", "Bar")
 	assert.Equal(vb, "Bar")
+
+	// Splitting at a value is not possible.
+	subcfg, err = cfg.Split("sub/a")
+	assert.Nil(subcfg)
+	assert.ErrorMatch(err, `.* cannot split configuration at "/etc/sub/a": path contains a value`)
+
+	// Setting values in the sub configuration must not
+	// change the original configuration.
+	subcfg, err = cfg.Split("sub")
+	assert.Nil(err)
+	assert.Nil(subcfg.Set("a", "Universe"))
+	assert.Equal(subcfg.ValueAsString("a", "Foo"), "Universe")
+	assert.Equal(cfg.ValueAsString("sub/a", "Foo"), "World")
+}
+
+// TestClone tests the cloning of configurations.
+func TestClone(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	source := "{etc {db {host localhost}{port 5432}}{hosts {0 alpha}{1 beta}}}"
+	cfg, err := etc.ReadString(source)
+	assert.Nil(err)
+	assert.Nil(cfg.SetPathSeparator("."))
+	assert.Nil(cfg.Redact("db/host"))
+
+	clone := cfg.Clone()
+	assert.Equal(clone.ValueAsString("db.host", "X"), "localhost")
+	assert.Equal(clone.String(), cfg.String())
+
+	// Changing the clone keeps the original unchanged.
+	assert.Nil(clone.Set("db.port", 6543))
+	assert.Nil(clone.Set("hosts.1", "gamma"))
+	assert.Nil(clone.Set("hosts.2", "delta"))
+	assert.Equal(clone.ValueAsInt("db.port", 0), 6543)
+	svs, err := clone.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "gamma", "delta"})
+	assert.Equal(cfg.ValueAsInt("db.port", 0), 5432)
+	svs, err = cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta"})
+
+	// And the other way round.
+	assert.Nil(cfg.Set("db.host", "db.example.com"))
+	assert.Equal(clone.ValueAsString("db.host", "X"), "localhost")
 }
 
 // TestDump tests the dumping of a configuration.
@@ -288,6 +1544,201 @@ func TestApply(t *testing.T) {
 	assert.Equal(vi, 42)
 }
 
+// TestApplyEnvOverrides tests the overriding of values
+// by environment variables.
+func TestApplyEnvOverrides(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	envs := map[string]string{
+		"GOLIB_ETC_ENV_SERVICE_DB_HOST":  "db.example.com",
+		"GOLIB_ETC_ENV_SERVICE_LOG__DIR": "/var/log/service",
+		"GOLIB_ETC_ENV_NEW_VALUE":        "42",
+		"GOLIB_ETC_ENVX_IGNORED":         "foo",
+	}
+	for key, value := range envs {
+		assert.Nil(os.Setenv(key, value))
+		defer os.Unsetenv(key)
+	}
+	cfg, err := etc.ReadString("{etc {service {db {host localhost}{port 5432}}{log-dir /tmp}}}")
+	assert.Nil(err)
+
+	ecfg, appl, err := cfg.ApplyEnvOverrides("GOLIB_ETC_ENV")
+	assert.Nil(err)
+	assert.Equal(appl, etc.Application{
+		"service/db/host": "db.example.com",
+		"service/log-dir": "/var/log/service",
+		"new/value":       "42",
+	})
+	assert.Equal(ecfg.ValueAsString("service/db/host", "X"), "db.example.com")
+	assert.Equal(ecfg.ValueAsInt("service/db/port", 0), 5432)
+	assert.Equal(ecfg.ValueAsString("service/log-dir", "X"), "/var/log/service")
+	assert.Equal(ecfg.ValueAsInt("new/value", 0), 42)
+	assert.False(ecfg.HasPath("ignored"))
+	assert.Equal(cfg.ValueAsString("service/db/host", "X"), "localhost")
+
+	// Prefix with underscore works the same.
+	_, appl, err = cfg.ApplyEnvOverrides("GOLIB_ETC_ENV_")
+	assert.Nil(err)
+	assert.Length(appl, 3)
+}
+
+// TestMerge tests the merging of configurations.
+func TestMerge(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	base, err := etc.ReadString(`{etc
+	{name base}
+	{db {host localhost}{port 5432}}
+	{hosts {0 alpha}{1 beta}}
+	{tags {0 a}}
+	{log {level info}}
+	}`)
+	assert.Nil(err)
+	overlay, err := etc.ReadString(`{etc
+	{db {host db.example.com}{user admin}}
+	{hosts {0 gamma}}
+	{log debug}
+	{extra {foo bar}}
+	}`)
+	assert.Nil(err)
+	more, err := etc.ReadString("{etc {name more}{tags {0 b}{1 c}}{db}}")
+	assert.Nil(err)
+
+	// Default replaces lists.
+	cfg, err := base.Merge(etc.ReplaceLists, overlay, more)
+	assert.Nil(err)
+	assert.Equal(cfg.ValueAsString("name", "X"), "more")
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "db.example.com")
+	assert.Equal(cfg.ValueAsInt("db/port", 0), 5432)
+	assert.Equal(cfg.ValueAsString("db/user", "X"), "admin")
+	assert.Equal(cfg.ValueAsString("extra/foo", "X"), "bar")
+	assert.Equal(cfg.ValueAsString("log", "X"), "debug")
+	assert.False(cfg.HasPath("log/level"))
+	svs, err := cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"gamma"})
+	svs, err = cfg.ValuesAt("tags")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"b", "c"})
+
+	// Appending lists.
+	cfg, err = base.Merge(etc.AppendLists, overlay, more)
+	assert.Nil(err)
+	svs, err = cfg.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta", "gamma"})
+	svs, err = cfg.ValuesAt("tags")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"a", "b", "c"})
+
+	// Inputs stay unchanged.
+	assert.Equal(base.ValueAsString("name", "X"), "base")
+	assert.Equal(base.ValueAsString("db/host", "X"), "localhost")
+	assert.Equal(base.ValueAsString("log/level", "X"), "info")
+	svs, err = base.ValuesAt("hosts")
+	assert.Nil(err)
+	assert.Equal(svs, []string{"alpha", "beta"})
+	assert.False(overlay.HasPath("db/port"))
+}
+
+// TestOnChange tests the notification about changed values.
+func TestOnChange(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	cfg, err := etc.ReadString("{etc {db {host localhost}{port 5432}}}")
+	assert.Nil(err)
+	var calls []string
+	listen := func(name string) etc.ChangeFunc {
+		return func(old, new string) {
+			calls = append(calls, name+": "+old+" -> "+new)
+		}
+	}
+	first := cfg.OnChange("db/host", listen("first"))
+	cfg.OnChange("db/host", listen("second"))
+	cfg.OnChange("db/user", listen("user"))
+	cfg.OnChange("db/port", func(old, new string) {
+		// The configuration can be used inside the function.
+		calls = append(calls, "port: "+cfg.ValueAsString("db/port", "X"))
+	})
+
+	assert.Nil(cfg.Set("db/host", "db.example.com"))
+	assert.Equal(calls, []string{"first: localhost -> db.example.com", "second: localhost -> db.example.com"})
+
+	// Setting the same value or other paths doesn't notify.
+	calls = nil
+	assert.Nil(cfg.Set("db/host", "db.example.com"))
+	assert.Nil(cfg.Set("db/name", "test"))
+	assert.Length(calls, 0)
+
+	// New values and removed listeners.
+	cfg.RemoveOnChange(first)
+	assert.Nil(cfg.Set("db/host", "localhost"))
+	assert.Nil(cfg.Set("db/user", "admin"))
+	assert.Nil(cfg.Set("db/port", 6543))
+	assert.Equal(calls, []string{"second: db.example.com -> localhost", "user:  -> admin", "port: 6543"})
+
+	// Merging notifies about the values of the merged configuration.
+	calls = nil
+	other, err := etc.ReadString("{etc {db {host db.example.com}{user admin}}}")
+	assert.Nil(err)
+	merged, err := cfg.Merge(etc.ReplaceLists, other)
+	assert.Nil(err)
+	assert.Equal(calls, []string{"second: localhost -> db.example.com"})
+	assert.Equal(cfg.ValueAsString("db/host", "X"), "localhost")
+
+	// Merged configurations don't inherit the listeners.
+	calls = nil
+	assert.Nil(merged.Set("db/host", "other.example.com"))
+	assert.Length(calls, 0)
+}
+
+// TestDiff tests the comparison of configurations.
+func TestDiff(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+
+	old, err := etc.ReadString(`{etc
+	{name service}
+	{db {host localhost}{port 5432}{password secret}}
+	{cache {size 10}{ttl 1m}}
+	{hosts {0 alpha}{1 beta}}
+	}`)
+	assert.Nil(err)
+	updated, err := etc.ReadString(`{etc
+	{name service}
+	{db {host db.example.com}{port 5432}{password other}}
+	{log {level debug}}
+	{hosts {0 alpha}}
+	}`)
+	assert.Nil(err)
+	assert.Nil(updated.Redact("db/password"))
+
+	changes := old.Diff(updated)
+	assert.Equal(changes, []etc.Change{
+		{Path: "cache", Kind: etc.ChangeRemoved},
+		{Path: "cache/size", Kind: etc.ChangeRemoved, Old: "10"},
+		{Path: "cache/ttl", Kind: etc.ChangeRemoved, Old: "1m"},
+		{Path: "db/host", Kind: etc.ChangeModified, Old: "localhost", New: "db.example.com"},
+		{Path: "db/password", Kind: etc.ChangeModified, Old: "secret", New: "***"},
+		{Path: "hosts/1", Kind: etc.ChangeRemoved, Old: "beta"},
+		{Path: "log", Kind: etc.ChangeAdded},
+		{Path: "log/level", Kind: etc.ChangeAdded, New: "debug"},
+	})
+	assert.Equal(changes[3].String(), `modified db/host: "localhost" -> "db.example.com"`)
+	assert.Equal(changes[7].String(), `added log/level: "debug"`)
+
+	// Equal configurations have no changes.
+	assert.Length(old.Diff(old), 0)
+
+	// Changed redacted values are detected but stay masked.
+	rotated, err := updated.Apply(etc.Application{"db/password": "rotated"})
+	assert.Nil(err)
+	assert.Nil(rotated.Redact("db/password"))
+	assert.Equal(updated.Diff(rotated), []etc.Change{
+		{Path: "db/password", Kind: etc.ChangeModified, Old: "***", New: "***"},
+	})
+	assert.Length(updated.Diff(updated), 0)
+}
+
 // TestContext tests adding a configuration to a context
 // an retrieve it again.
 func TestContext(t *testing.T) {
@@ -309,6 +1760,17 @@ func TestContext(t *testing.T) {
 	assert.Equal(vs, "Hello")
 	vs = yesCfg.ValueAsString("sub/a", "bar")
 	assert.Equal(vs, "World")
+
+	// Derived contexts may carry overriding configurations.
+	override, err := cfg.Apply(etc.Application{"a": "Override"})
+	assert.Nil(err)
+	overrideCtx := etc.NewContext(cfgCtx, override)
+	overrideCfg, ok := etc.FromContext(overrideCtx)
+	assert.True(ok)
+	assert.Equal(overrideCfg.ValueAsString("a", "foo"), "Override")
+	yesCfg, ok = etc.FromContext(cfgCtx)
+	assert.True(ok)
+	assert.Equal(yesCfg.ValueAsString("a", "foo"), "Hello")
 }
 
 // EOF
@@ -0,0 +1,198 @@
+// Tideland Go Library - Etc - JSON
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/tideland/golib/collections"
+	"github.com/tideland/golib/errors"
+)
+
+//--------------------
+// JSON SOURCE
+//--------------------
+
+// NewJSONReader returns a reader converting the passed JSON
+// document into SML, so it can be used with Read(). The document
+// has to be an object, its fields become the nodes below the "etc"
+// root. Nested objects become nested nodes and the elements of an
+// array become child nodes named by their index, starting at "0".
+// Numbers are kept in their textual form, booleans are written
+// as "true" or "false", and null leads to an empty value. All keys
+// are lowercased, underscores are replaced by dashes, and then they
+// have to be valid SML tags. So "db_host" is read as "db-host".
+//
+//	cfg, err := etc.Read(etc.NewJSONReader(file))
+func NewJSONReader(source io.Reader) io.Reader {
+	return newSourceReader(source, convertJSON)
+}
+
+// convertJSON converts the JSON document into SML.
+func convertJSON(source io.Reader, enc *smlEncoder) error {
+	decoder := json.NewDecoder(source)
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "JSON", "invalid document")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.New(ErrCannotConvertSource, errorMessages, "JSON", "document is no object")
+	}
+	enc.openTag("etc")
+	if err = convertJSONObject(decoder, enc); err != nil {
+		return err
+	}
+	enc.closeTag()
+	if _, err = decoder.Token(); err != io.EOF {
+		return errors.New(ErrCannotConvertSource, errorMessages, "JSON", "data after document")
+	}
+	return nil
+}
+
+// convertJSONObject converts the fields of an object after
+// its opening delimiter has been read.
+func convertJSONObject(decoder *json.Decoder, enc *smlEncoder) error {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "JSON", "invalid document")
+		}
+		if err = enc.openTag(sourceKey(token.(string))); err != nil {
+			return err
+		}
+		if err = convertJSONValue(decoder, enc); err != nil {
+			return err
+		}
+		enc.closeTag()
+	}
+	return readJSONDelim(decoder)
+}
+
+// convertJSONArray converts the elements of an array after
+// its opening delimiter has been read.
+func convertJSONArray(decoder *json.Decoder, enc *smlEncoder) error {
+	for index := 0; decoder.More(); index++ {
+		enc.openTag(strconv.Itoa(index))
+		if err := convertJSONValue(decoder, enc); err != nil {
+			return err
+		}
+		enc.closeTag()
+	}
+	return readJSONDelim(decoder)
+}
+
+// convertJSONValue converts the next value.
+func convertJSONValue(decoder *json.Decoder, enc *smlEncoder) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "JSON", "invalid document")
+	}
+	switch tt := token.(type) {
+	case json.Delim:
+		if tt == '{' {
+			return convertJSONObject(decoder, enc)
+		}
+		return convertJSONArray(decoder, enc)
+	case json.Number:
+		enc.text(tt.String())
+	case string:
+		enc.text(tt)
+	case bool:
+		enc.text(strconv.FormatBool(tt))
+	}
+	return nil
+}
+
+// readJSONDelim reads the closing delimiter of an
+// object or an array.
+func readJSONDelim(decoder *json.Decoder) error {
+	if _, err := decoder.Token(); err != nil {
+		return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "JSON", "invalid document")
+	}
+	return nil
+}
+
+//--------------------
+// JSON OUTPUT
+//--------------------
+
+// MarshalJSON implements the json.Marshaler interface.
+func (e *etc) MarshalJSON() ([]byte, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	var buf bytes.Buffer
+	if err := marshalJSONValues(&buf, e.redactedValues(), etcRoot); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalJSONValues writes the node at the path as JSON. Nodes
+// with children become objects or, in case of lists, arrays. Empty
+// nodes are empty lists. The root is always an object.
+func marshalJSONValues(buf *bytes.Buffer, tree collections.KeyStringValueTree, path []string) error {
+	changer := tree.At(path...)
+	children, err := changer.List()
+	if err != nil {
+		return err
+	}
+	if len(children) == 0 && len(path) > 1 {
+		value, err := changer.Value()
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			buf.WriteString("[]")
+			return nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+	list := len(path) > 1 && isList(children)
+	if list {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteByte('{')
+	}
+	for i, child := range children {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if !list {
+			data, err := json.Marshal(child.Key)
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+			buf.WriteByte(':')
+		}
+		if err = marshalJSONValues(buf, tree, appendPath(path, child.Key)); err != nil {
+			return err
+		}
+	}
+	if list {
+		buf.WriteByte(']')
+	} else {
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// EOF
@@ -0,0 +1,104 @@
+// Tideland Go Library - Etc - Listener
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	"github.com/tideland/golib/collections"
+)
+
+//--------------------
+// LISTENER
+//--------------------
+
+// ChangeFunc is called with the old and the new value
+// of a path when it changes.
+type ChangeFunc func(old, new string)
+
+// listener contains a function registered for a path.
+type listener struct {
+	id  int
+	key string
+	fn  ChangeFunc
+	old string
+	new string
+}
+
+// OnChange implements the Etc interface.
+func (e *etc) OnChange(path string, fn ChangeFunc) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.listenerID++
+	e.listeners = append(e.listeners, listener{
+		id:  e.listenerID,
+		key: strings.Join(e.fullPath(path, e.separator), "/"),
+		fn:  fn,
+	})
+	return e.listenerID
+}
+
+// RemoveOnChange implements the Etc interface.
+func (e *etc) RemoveOnChange(id int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for i, l := range e.listeners {
+		if l.id == id {
+			e.listeners = append(e.listeners[:i:i], e.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// watchedValues returns copies of the listeners with the
+// current values of their paths.
+func (e *etc) watchedValues() []listener {
+	ls := make([]listener, len(e.listeners))
+	for i, l := range e.listeners {
+		l.old = valueOf(e.values, l.key)
+		ls[i] = l
+	}
+	return ls
+}
+
+// changedValues returns the listeners whose values differ
+// in the tree, together with the new values.
+func changedValues(ls []listener, tree collections.KeyStringValueTree) []listener {
+	var changed []listener
+	for _, l := range ls {
+		if l.new = valueOf(tree, l.key); l.new != l.old {
+			changed = append(changed, l)
+		}
+	}
+	return changed
+}
+
+// notifyChanges calls the functions of the changed listeners,
+// it must be called without holding the mutex.
+func notifyChanges(changed []listener) {
+	for _, l := range changed {
+		l.fn(l.old, l.new)
+	}
+}
+
+// valueOf returns the value of a leaf at the slash separated
+// key. Missing nodes and nodes with children have no value.
+func valueOf(tree collections.KeyStringValueTree, key string) string {
+	changer := tree.At(strings.Split(key, "/")...)
+	if children, err := changer.List(); err != nil || len(children) > 0 {
+		return ""
+	}
+	value, _ := changer.Value()
+	return value
+}
+
+// EOF
@@ -0,0 +1,158 @@
+// Tideland Go Library - Etc - Sources
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/tideland/golib/errors"
+)
+
+//--------------------
+// SOURCE READER
+//--------------------
+
+// convertFunc converts a source in a foreign format into SML
+// using the passed encoder.
+type convertFunc func(source io.Reader, enc *smlEncoder) error
+
+// sourceReader implements io.Reader. During the first read the
+// foreign source is converted into SML, which then is returned
+// by this and following reads.
+type sourceReader struct {
+	source  io.Reader
+	convert convertFunc
+	sml     *bytes.Buffer
+	err     error
+}
+
+// newSourceReader creates a reader converting the source
+// with the passed function.
+func newSourceReader(source io.Reader, convert convertFunc) io.Reader {
+	return &sourceReader{
+		source:  source,
+		convert: convert,
+	}
+}
+
+// Read implements the io.Reader interface.
+func (sr *sourceReader) Read(p []byte) (int, error) {
+	if sr.sml == nil {
+		enc := newSMLEncoder()
+		sr.err = sr.convert(sr.source, enc)
+		sr.sml = enc.bytes()
+	}
+	if sr.err != nil {
+		return 0, sr.err
+	}
+	return sr.sml.Read(p)
+}
+
+//--------------------
+// SML ENCODER
+//--------------------
+
+// validKeyRe checks if a key can be used as SML tag.
+var validKeyRe = regexp.MustCompile(`^[a-zA-Z0-9:\-]+$`)
+
+// sourceKey maps a key of a converted document to its SML tag.
+// It's lowercased and underscores are replaced by dashes, so
+// snake_case keys like "db_host" become "db-host".
+func sourceKey(key string) string {
+	return strings.Replace(strings.ToLower(key), "_", "-", -1)
+}
+
+// smlEncoder writes nodes and their values in SML notation. Nodes
+// with children are written on multiple lines, one tab per level.
+type smlEncoder struct {
+	buf      bytes.Buffer
+	children []bool
+}
+
+// newSMLEncoder creates an empty encoder.
+func newSMLEncoder() *smlEncoder {
+	return &smlEncoder{}
+}
+
+// openTag starts a new node with the passed tag.
+func (enc *smlEncoder) openTag(tag string) error {
+	if !validKeyRe.MatchString(tag) {
+		return errors.New(ErrIllegalSourceKey, errorMessages, tag)
+	}
+	if l := len(enc.children); l > 0 {
+		enc.children[l-1] = true
+		enc.buf.WriteString("\n")
+		enc.buf.WriteString(strings.Repeat("\t", l))
+	}
+	enc.buf.WriteString("{")
+	enc.buf.WriteString(tag)
+	enc.children = append(enc.children, false)
+	return nil
+}
+
+// comment writes a comment on its own line in
+// the current node.
+func (enc *smlEncoder) comment(comment string) {
+	l := len(enc.children)
+	if l > 0 {
+		enc.children[l-1] = true
+		enc.buf.WriteString("\n")
+		enc.buf.WriteString(strings.Repeat("\t", l))
+	}
+	enc.buf.WriteString("{# ")
+	enc.buf.WriteString(comment)
+	enc.buf.WriteString(" #}")
+}
+
+// text writes the value of the current node. Like when reading
+// it is trimmed, special characters will be escaped.
+func (enc *smlEncoder) text(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	enc.buf.WriteString(" ")
+	for _, r := range text {
+		switch r {
+		case '^', '{', '}':
+			enc.buf.WriteRune('^')
+		}
+		enc.buf.WriteRune(r)
+	}
+}
+
+// closeTag ends the current node.
+func (enc *smlEncoder) closeTag() {
+	l := len(enc.children)
+	if l == 0 {
+		return
+	}
+	if enc.children[l-1] {
+		enc.buf.WriteString("\n")
+		enc.buf.WriteString(strings.Repeat("\t", l-1))
+	}
+	enc.buf.WriteString("}")
+	enc.children = enc.children[:l-1]
+	if l == 1 {
+		enc.buf.WriteString("\n")
+	}
+}
+
+// bytes returns the buffer containing the written SML.
+func (enc *smlEncoder) bytes() *bytes.Buffer {
+	return &enc.buf
+}
+
+// EOF
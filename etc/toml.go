@@ -0,0 +1,631 @@
+// Tideland Go Library - Etc - TOML
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tideland/golib/errors"
+)
+
+//--------------------
+// TOML SOURCE
+//--------------------
+
+// NewTOMLReader returns a reader converting the passed TOML
+// document into SML, so it can be used with Read(). The keys of
+// the document become the nodes below the "etc" root, tables and
+// inline tables become nested nodes. Arrays as well as arrays of
+// tables become child nodes named by their index, starting at "0",
+// so their values can be retrieved with ValuesAt(), IntValuesAt(),
+// or Float64ValuesAt(). As all values are strings the elements of
+// an array don't need to have the same type, nested arrays become
+// nested lists.
+//
+// Strings are unescaped, integers are written in decimal notation
+// without underscores, and booleans as "true" or "false". Offset
+// date-times are normalized to RFC 3339 with "T" as delimiter, so
+// they can be retrieved with ValueAsTime() and time.RFC3339 as
+// layout. Local date-times, dates, and times keep their form. Like
+// all values strings are trimmed. All keys are lowercased, underscores
+// are replaced by dashes, and then they have to be valid SML tags. So
+// "db_host" is read as "db-host".
+//
+//	cfg, err := etc.Read(etc.NewTOMLReader(file))
+func NewTOMLReader(source io.Reader) io.Reader {
+	return newSourceReader(source, convertTOML)
+}
+
+// convertTOML converts the TOML document into SML.
+func convertTOML(source io.Reader, enc *smlEncoder) error {
+	data, err := ioutil.ReadAll(source)
+	if err != nil {
+		return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "TOML", "unreadable document")
+	}
+	p := newTOMLParser(strings.Replace(string(data), "\r\n", "\n", -1))
+	if err = p.parse(); err != nil {
+		return err
+	}
+	p.root.key = "etc"
+	return p.root.encode(enc)
+}
+
+//--------------------
+// TOML NODES
+//--------------------
+
+// tomlKind describes the kind of a TOML node.
+type tomlKind int
+
+const (
+	tomlValue tomlKind = iota
+	tomlTable
+	tomlArray
+)
+
+// tomlNode is a node of the TOML document. Tables and
+// arrays keep their children in document order.
+type tomlNode struct {
+	key      string
+	value    string
+	kind     tomlKind
+	defined  bool
+	tables   bool
+	children []*tomlNode
+}
+
+// child returns the child with the key or nil.
+func (n *tomlNode) child(key string) *tomlNode {
+	for _, child := range n.children {
+		if child.key == key {
+			return child
+		}
+	}
+	return nil
+}
+
+// add appends a child, the children of arrays are
+// named by their index.
+func (n *tomlNode) add(key string, child *tomlNode) *tomlNode {
+	if n.kind == tomlArray {
+		key = strconv.Itoa(len(n.children))
+	}
+	child.key = key
+	n.children = append(n.children, child)
+	return child
+}
+
+// encode writes the node and its children with the encoder.
+func (n *tomlNode) encode(enc *smlEncoder) error {
+	if err := enc.openTag(sourceKey(n.key)); err != nil {
+		return err
+	}
+	enc.text(n.value)
+	for _, child := range n.children {
+		if err := child.encode(enc); err != nil {
+			return err
+		}
+	}
+	enc.closeTag()
+	return nil
+}
+
+//--------------------
+// TOML PARSER
+//--------------------
+
+var (
+	tomlDateTimeRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?$`)
+	tomlTimeRe     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlDateRe     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// tomlParser converts a TOML document into a tree of nodes.
+type tomlParser struct {
+	data    string
+	pos     int
+	root    *tomlNode
+	current *tomlNode
+}
+
+// newTOMLParser creates a parser for the document.
+func newTOMLParser(data string) *tomlParser {
+	root := &tomlNode{
+		kind:    tomlTable,
+		defined: true,
+	}
+	return &tomlParser{
+		data:    data,
+		root:    root,
+		current: root,
+	}
+}
+
+// parse parses the expressions of the document.
+func (p *tomlParser) parse() error {
+	for {
+		p.skipBlanks(true)
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.parseLineEnd(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseHeader parses a table or array of tables header.
+func (p *tomlParser) parseHeader() error {
+	p.pos++
+	array := p.peek() == '['
+	if array {
+		p.pos++
+	}
+	p.skipBlanks(false)
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.data[p.pos:], closing) {
+		return p.error("missing %q of table header", closing)
+	}
+	p.pos += len(closing)
+	table, err := p.descend(p.root, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	node := table.child(last)
+	switch {
+	case array && node == nil:
+		node = table.add(last, &tomlNode{kind: tomlArray, tables: true})
+		fallthrough
+	case array && node.kind == tomlArray && node.tables:
+		p.current = node.add("", &tomlNode{kind: tomlTable, defined: true})
+	case !array && node == nil:
+		p.current = table.add(last, &tomlNode{kind: tomlTable, defined: true})
+	case !array && node.kind == tomlTable && !node.defined:
+		node.defined = true
+		p.current = node
+	default:
+		return p.error("key %q is already defined", strings.Join(keys, "."))
+	}
+	return nil
+}
+
+// parseKeyValue parses a key/value pair and adds it to the table.
+func (p *tomlParser) parseKeyValue(table *tomlNode) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.error("missing '=' after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipBlanks(false)
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	table, err = p.descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if table.child(last) != nil {
+		return p.error("key %q is already defined", strings.Join(keys, "."))
+	}
+	table.add(last, value)
+	return nil
+}
+
+// descend walks along the keys starting at the table, missing
+// tables are created. For arrays of tables the last one is used.
+func (p *tomlParser) descend(table *tomlNode, keys []string) (*tomlNode, error) {
+	for i, key := range keys {
+		node := table.child(key)
+		switch {
+		case node == nil:
+			node = table.add(key, &tomlNode{kind: tomlTable})
+		case node.kind == tomlArray && node.tables:
+			node = node.children[len(node.children)-1]
+		case node.kind != tomlTable:
+			return nil, p.error("key %q is already defined", strings.Join(keys[:i+1], "."))
+		}
+		table = node
+	}
+	return table, nil
+}
+
+// parseKey parses a simple or dotted key and the
+// following blanks.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		var key string
+		var err error
+		switch p.peek() {
+		case '"':
+			key, err = p.parseBasicString()
+		case '\'':
+			key, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for !p.eof() && isTOMLBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			key = p.data[start:p.pos]
+			if key == "" {
+				return nil, p.error("missing key")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		p.skipBlanks(false)
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.skipBlanks(false)
+	}
+}
+
+// parseValue parses a string, array, inline table, or
+// other scalar.
+func (p *tomlParser) parseValue() (*tomlNode, error) {
+	var value string
+	var err error
+	switch {
+	case strings.HasPrefix(p.data[p.pos:], `"""`):
+		value, err = p.parseMultiLineBasicString()
+	case strings.HasPrefix(p.data[p.pos:], `'''`):
+		value, err = p.parseMultiLineLiteralString()
+	case p.peek() == '"':
+		value, err = p.parseBasicString()
+	case p.peek() == '\'':
+		value, err = p.parseLiteralString()
+	case p.peek() == '[':
+		return p.parseArray()
+	case p.peek() == '{':
+		return p.parseInlineTable()
+	default:
+		value, err = p.parseScalar()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tomlNode{value: value}, nil
+}
+
+// parseArray parses an array, it may span multiple lines.
+func (p *tomlParser) parseArray() (*tomlNode, error) {
+	array := &tomlNode{kind: tomlArray}
+	p.pos++
+	for {
+		p.skipBlanks(true)
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		if p.eof() {
+			return nil, p.error("unterminated array")
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array.add("", value)
+		p.skipBlanks(true)
+		switch {
+		case p.eof():
+			return nil, p.error("unterminated array")
+		case p.peek() == ',':
+			p.pos++
+		case p.peek() != ']':
+			return nil, p.error("missing ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parses an inline table on one line.
+func (p *tomlParser) parseInlineTable() (*tomlNode, error) {
+	table := &tomlNode{kind: tomlTable, defined: true}
+	p.pos++
+	p.skipBlanks(false)
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlanks(false)
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipBlanks(false)
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.error("missing ',' or '}' in inline table")
+		}
+	}
+}
+
+// parseScalar parses booleans, numbers, and date-times.
+func (p *tomlParser) parseScalar() (string, error) {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	scalar := p.data[start:p.pos]
+	// A date may be followed by a space and the time.
+	if tomlDateRe.MatchString(scalar) && p.pos+1 < len(p.data) && p.peek() == ' ' {
+		if c := p.data[p.pos+1]; c >= '0' && c <= '9' {
+			p.pos++
+			for !p.eof() && !strings.ContainsRune(" \t\n,]}#", rune(p.peek())) {
+				p.pos++
+			}
+			scalar = p.data[start:p.pos]
+		}
+	}
+	switch {
+	case scalar == "":
+		return "", p.error("missing value")
+	case scalar == "true" || scalar == "false":
+		return scalar, nil
+	case tomlDateTimeRe.MatchString(scalar):
+		if len(scalar) > 10 {
+			scalar = scalar[:10] + "T" + strings.ToUpper(scalar[11:])
+		}
+		return scalar, nil
+	case tomlTimeRe.MatchString(scalar):
+		return scalar, nil
+	}
+	number := strings.Replace(scalar, "_", "", -1)
+	if strings.HasPrefix(number, "0x") || strings.HasPrefix(number, "0o") || strings.HasPrefix(number, "0b") {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[number[1]]
+		i, err := strconv.ParseInt(number[2:], base, 64)
+		if err != nil {
+			return "", p.error("invalid integer %q", scalar)
+		}
+		return strconv.FormatInt(i, 10), nil
+	}
+	if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return strings.TrimPrefix(number, "+"), nil
+	}
+	return "", p.error("invalid value %q", scalar)
+}
+
+// parseBasicString parses a string in double quotes.
+func (p *tomlParser) parseBasicString() (string, error) {
+	var buf []rune
+	p.pos++
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.error("unterminated string")
+		}
+		switch c := p.peek(); c {
+		case '"':
+			p.pos++
+			return string(buf), nil
+		case '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, r)
+		default:
+			r, size := utf8.DecodeRuneInString(p.data[p.pos:])
+			buf = append(buf, r)
+			p.pos += size
+		}
+	}
+}
+
+// parseMultiLineBasicString parses a string in triple
+// double quotes.
+func (p *tomlParser) parseMultiLineBasicString() (string, error) {
+	var buf []rune
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.pos++
+	}
+	for {
+		if p.eof() {
+			return "", p.error("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.data[p.pos:], `"""`) {
+			p.pos += 3
+			return string(buf), nil
+		}
+		switch c := p.peek(); c {
+		case '\\':
+			// A backslash at the end of a line trims the
+			// following whitespace.
+			rest := strings.TrimLeft(p.data[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") {
+				p.pos = len(p.data) - len(strings.TrimLeft(rest, " \t\n"))
+				continue
+			}
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, r)
+		default:
+			r, size := utf8.DecodeRuneInString(p.data[p.pos:])
+			buf = append(buf, r)
+			p.pos += size
+		}
+	}
+}
+
+// parseEscape parses an escape sequence in a basic string.
+func (p *tomlParser) parseEscape() (rune, error) {
+	p.pos++
+	if p.eof() {
+		return 0, p.error("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'f':
+		return '\f', nil
+	case 'r':
+		return '\r', nil
+	case '"':
+		return '"', nil
+	case '\\':
+		return '\\', nil
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.data) {
+			return 0, p.error("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.data[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return 0, p.error("invalid unicode escape")
+		}
+		p.pos += size
+		return rune(code), nil
+	}
+	return 0, p.error("invalid escape sequence '\\%c'", c)
+}
+
+// parseLiteralString parses a string in single quotes.
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.data[p.pos:], "'\n")
+	if end < 0 || p.data[p.pos+end] != '\'' {
+		return "", p.error("unterminated string")
+	}
+	literal := p.data[p.pos : p.pos+end]
+	p.pos += end + 1
+	return literal, nil
+}
+
+// parseMultiLineLiteralString parses a string in triple
+// single quotes.
+func (p *tomlParser) parseMultiLineLiteralString() (string, error) {
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.pos++
+	}
+	end := strings.Index(p.data[p.pos:], `'''`)
+	if end < 0 {
+		return "", p.error("unterminated multi-line string")
+	}
+	literal := p.data[p.pos : p.pos+end]
+	p.pos += end + 3
+	return literal, nil
+}
+
+// parseLineEnd checks that only blanks and a comment
+// follow until the end of the line.
+func (p *tomlParser) parseLineEnd() error {
+	p.skipBlanks(false)
+	if !p.eof() && p.peek() != '\n' {
+		return p.error("unexpected characters after expression")
+	}
+	return nil
+}
+
+// skipBlanks skips spaces, tabs, and comments. With
+// newlines set also the ends of lines are skipped.
+func (p *tomlParser) skipBlanks(newlines bool) {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.pos++
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.pos++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the current byte or 0 at the end.
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+// eof checks if the end of the document is reached.
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// error creates a conversion error for the current line.
+func (p *tomlParser) error(format string, args ...interface{}) error {
+	line := strings.Count(p.data[:p.pos], "\n") + 1
+	msg := fmt.Sprintf(format, args...)
+	return errors.New(ErrCannotConvertSource, errorMessages, "TOML", fmt.Sprintf("line %d: %s", line, msg))
+}
+
+//--------------------
+// TOML HELPERS
+//--------------------
+
+// isTOMLBareKeyChar checks if the character is allowed
+// in bare keys.
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// EOF
@@ -0,0 +1,190 @@
+// Tideland Go Library - Etc - Unmarshal
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tideland/golib/collections"
+	"github.com/tideland/golib/errors"
+	"github.com/tideland/golib/stringex"
+)
+
+//--------------------
+// UNMARSHAL
+//--------------------
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Unmarshal implements the Etc interface.
+func (e *etc) Unmarshal(target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New(ErrIllegalTarget, errorMessages, "no pointer to a struct")
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if err := e.unmarshalStruct(rv.Elem(), etcRoot); err != nil {
+		return errors.Annotate(err, ErrCannotUnmarshal, errorMessages)
+	}
+	return nil
+}
+
+// unmarshalStruct sets the fields of the struct with the
+// values below the full path.
+func (e *etc) unmarshalStruct(sv reflect.Value, structPath []string) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		tag := field.Tag.Get("etc")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		fieldPath := parts[0]
+		if fieldPath == "" {
+			fieldPath = field.Name
+		}
+		required := len(parts) > 1 && parts[1] == "required"
+		fullPath := append(append([]string{}, structPath...), makeFullPath(fieldPath, defaultSeparator)[1:]...)
+		fv := sv.Field(i)
+		changer, err := e.lookup(fullPath)
+		if err != nil {
+			if required {
+				return err
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				// Check the required fields of the struct.
+				if err = e.unmarshalStruct(fv, fullPath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			if err = e.unmarshalStruct(fv, fullPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Slice {
+			if err = e.unmarshalSlice(fv, fullPath, changer); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := changer.Value()
+		if err != nil {
+			return err
+		}
+		if value, err = e.expand(value); err != nil {
+			return err
+		}
+		if err = setField(fv, value, fullPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalSlice sets a slice field with the values of
+// the child nodes. Like ValuesAt a node without children
+// leads to a slice with its value as only element.
+func (e *etc) unmarshalSlice(fv reflect.Value, fullPath []string, changer collections.KeyStringValueChanger) error {
+	kvs, err := changer.List()
+	if err != nil {
+		return err
+	}
+	if len(kvs) == 0 {
+		if sv, _ := changer.Value(); sv != "" {
+			kvs = []collections.KeyStringValue{{Value: sv}}
+		}
+	}
+	slice := reflect.MakeSlice(fv.Type(), len(kvs), len(kvs))
+	for i, kv := range kvs {
+		value, err := e.expand(kv.Value)
+		if err != nil {
+			return err
+		}
+		if err = setField(slice.Index(i), value, appendPath(fullPath, kv.Key)); err != nil {
+			return err
+		}
+	}
+	fv.Set(slice)
+	return nil
+}
+
+// setField parses the value and sets the field.
+func setField(fv reflect.Value, value string, fullPath []string) error {
+	illegal := func(err error) error {
+		return errors.Annotate(err, ErrIllegalValue, errorMessages, value, pathToString(fullPath), fv.Type())
+	}
+	switch {
+	case fv.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return illegal(err)
+		}
+		fv.SetInt(int64(d))
+	case fv.Type() == timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return illegal(err)
+		}
+		fv.Set(reflect.ValueOf(t))
+	default:
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(value)
+		case reflect.Bool:
+			bv, _ := (&boolValue{stringex.StringValuer(value)}).Value()
+			b, err := strconv.ParseBool(bv)
+			if err != nil {
+				return illegal(err)
+			}
+			fv.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+			if err != nil {
+				return illegal(err)
+			}
+			fv.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+			if err != nil {
+				return illegal(err)
+			}
+			fv.SetUint(u)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(value, fv.Type().Bits())
+			if err != nil {
+				return illegal(err)
+			}
+			fv.SetFloat(f)
+		default:
+			return errors.New(ErrIllegalTarget, errorMessages, "unsupported field type "+fv.Type().String())
+		}
+	}
+	return nil
+}
+
+// EOF
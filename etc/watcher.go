@@ -0,0 +1,178 @@
+// Tideland Go Library - Etc - Watcher
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tideland/golib/errors"
+	"github.com/tideland/golib/loop"
+)
+
+//--------------------
+// WATCHER
+//--------------------
+
+// Watcher polls a configuration file and reads it again
+// whenever its modification time or size changes.
+type Watcher interface {
+	// Current returns the last successfully read configuration.
+	Current() Etc
+
+	// Changes returns a channel receiving each newly read
+	// configuration. If it is not received in time only
+	// the latest one is kept.
+	Changes() <-chan Etc
+
+	// Errors returns a channel receiving the errors of reading
+	// the file. In this case the current configuration stays
+	// the same. Like with the changes only the latest error
+	// is kept.
+	Errors() <-chan error
+
+	// Close stops the watcher and closes the channels. Further
+	// calls only return the error of the first one.
+	Close() error
+}
+
+// watcher implements the Watcher interface.
+type watcher struct {
+	mutex    sync.RWMutex
+	filename string
+	pollTime time.Duration
+	modTime  time.Time
+	size     int64
+	current  Etc
+	changesC chan Etc
+	errorsC  chan error
+	loop     loop.Loop
+
+	closeMutex sync.Mutex
+	closed     bool
+	closeErr   error
+}
+
+// NewWatcher reads the configuration file and starts watching
+// it in the given poll time.
+func NewWatcher(filename string, pollTime time.Duration) (Watcher, error) {
+	if pollTime <= 0 {
+		return nil, errors.New(ErrIllegalPollTime, errorMessages, pollTime)
+	}
+	w := &watcher{
+		filename: filename,
+		pollTime: pollTime,
+		changesC: make(chan Etc, 1),
+		errorsC:  make(chan error, 1),
+	}
+	if _, err := w.check(); err != nil {
+		return nil, err
+	}
+	w.loop = loop.Go(w.backendLoop, "etc", "watcher", filename)
+	return w, nil
+}
+
+// Current implements the Watcher interface.
+func (w *watcher) Current() Etc {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.current
+}
+
+// Changes implements the Watcher interface.
+func (w *watcher) Changes() <-chan Etc {
+	return w.changesC
+}
+
+// Errors implements the Watcher interface.
+func (w *watcher) Errors() <-chan error {
+	return w.errorsC
+}
+
+// Close implements the Watcher interface.
+func (w *watcher) Close() error {
+	w.closeMutex.Lock()
+	defer w.closeMutex.Unlock()
+	if w.closed {
+		return w.closeErr
+	}
+	w.closed = true
+	w.closeErr = w.loop.Stop()
+	close(w.changesC)
+	close(w.errorsC)
+	return w.closeErr
+}
+
+// backendLoop polls the file.
+func (w *watcher) backendLoop(l loop.Loop) error {
+	ticker := time.NewTicker(w.pollTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.ShallStop():
+			return nil
+		case <-ticker.C:
+			changed, err := w.check()
+			if err != nil {
+				w.notifyError(err)
+			} else if changed {
+				w.notifyChange(w.Current())
+			}
+		}
+	}
+}
+
+// check reads the file again if it changed. Modification time
+// and size are only recorded after a successful read, so a file
+// failing to read is tried again with the next poll.
+func (w *watcher) check() (bool, error) {
+	fi, err := os.Stat(w.filename)
+	if err != nil {
+		return false, errors.Annotate(err, ErrCannotReadFile, errorMessages, w.filename)
+	}
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return false, nil
+	}
+	cfg, err := ReadFile(w.filename)
+	if err != nil {
+		return false, err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.modTime = fi.ModTime()
+	w.size = fi.Size()
+	w.current = cfg
+	return true, nil
+}
+
+// notifyChange sends the configuration, a not yet
+// received one is replaced.
+func (w *watcher) notifyChange(cfg Etc) {
+	select {
+	case <-w.changesC:
+	default:
+	}
+	w.changesC <- cfg
+}
+
+// notifyError sends the error, a not yet received
+// one is replaced.
+func (w *watcher) notifyError(err error) {
+	select {
+	case <-w.errorsC:
+	default:
+	}
+	w.errorsC <- err
+}
+
+// EOF
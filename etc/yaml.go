@@ -0,0 +1,612 @@
+// Tideland Go Library - Etc - YAML
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package etc
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/tideland/golib/errors"
+)
+
+//--------------------
+// YAML SOURCE
+//--------------------
+
+// NewYAMLReader returns a reader converting the passed YAML
+// document into SML, so it can be used with Read(). The top level
+// of the document has to be a mapping, its keys become the nodes
+// below the "etc" root. Nested mappings become nested nodes and the
+// items of a sequence become child nodes named by their index,
+// starting at "0". Scalars keep their string form, "~" and "null"
+// lead to empty values. An empty document results in an empty
+// configuration. All keys are lowercased, underscores are replaced
+// by dashes, and then they have to be valid SML tags. So "max_conn"
+// is read as "max-conn".
+//
+// Only a subset of YAML is supported: block mappings and sequences,
+// plain and quoted scalars, literal and folded block scalars as well
+// as single line flow collections. Anchors, aliases, and tags are
+// not supported and lead to an error, the same for multiple
+// documents in one source. Like all values, block scalars are
+// trimmed, so chomping indicators are accepted but have no effect.
+//
+//	cfg, err := etc.Read(etc.NewYAMLReader(file))
+func NewYAMLReader(source io.Reader) io.Reader {
+	return newSourceReader(source, convertYAML)
+}
+
+// convertYAML converts the YAML document into SML.
+func convertYAML(source io.Reader, enc *smlEncoder) error {
+	data, err := ioutil.ReadAll(source)
+	if err != nil {
+		return errors.Annotate(err, ErrCannotConvertSource, errorMessages, "YAML", "unreadable document")
+	}
+	p, err := newYAMLParser(string(data), enc)
+	if err != nil {
+		return err
+	}
+	enc.openTag("etc")
+	if p.skip() {
+		line := p.current()
+		if isYAMLSequenceItem(line.text) {
+			return p.error(line, "document is no mapping")
+		}
+		if err = p.parseMapping(line.indent); err != nil {
+			return err
+		}
+		if p.skip() {
+			return p.error(p.current(), "unexpected indentation")
+		}
+	}
+	enc.closeTag()
+	return nil
+}
+
+//--------------------
+// YAML PARSER
+//--------------------
+
+// yamlLine contains one line of the YAML document. The text
+// is trimmed and cleared from comments.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+	raw    string
+}
+
+// yamlParser converts a YAML document line by line.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+	enc   *smlEncoder
+}
+
+// newYAMLParser splits the document into lines and checks
+// the document markers.
+func newYAMLParser(data string, enc *smlEncoder) (*yamlParser, error) {
+	p := &yamlParser{
+		enc: enc,
+	}
+	started := false
+	for i, raw := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		line := yamlLine{
+			number: i + 1,
+			raw:    raw,
+		}
+		text := strings.TrimLeft(raw, " ")
+		line.indent = len(raw) - len(text)
+		line.text = stripYAMLComment(text)
+		if strings.HasPrefix(line.text, "\t") {
+			return nil, p.error(line, "tabs are not allowed for indentation")
+		}
+		if line.indent == 0 {
+			switch {
+			case line.text == "---" || strings.HasPrefix(line.text, "--- "):
+				if started {
+					return nil, p.error(line, "multiple documents are not supported")
+				}
+				line.text = strings.TrimSpace(line.text[3:])
+				if line.text != "" {
+					return nil, p.error(line, "document is no mapping")
+				}
+			case line.text == "...":
+				return p, nil
+			case strings.HasPrefix(line.text, "%"):
+				return nil, p.error(line, "directives are not supported")
+			}
+		}
+		started = started || line.text != ""
+		p.lines = append(p.lines, line)
+	}
+	return p, nil
+}
+
+// skip moves to the next non-empty line and returns
+// false if the end of the document is reached.
+func (p *yamlParser) skip() bool {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+	return p.pos < len(p.lines)
+}
+
+// current returns the current line.
+func (p *yamlParser) current() yamlLine {
+	return p.lines[p.pos]
+}
+
+// parseBlock parses the mapping, sequence, or multi-line
+// plain scalar starting at the current line.
+func (p *yamlParser) parseBlock(indent int) error {
+	line := p.current()
+	if isYAMLSequenceItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(indent)
+	}
+	var parts []string
+	for p.skip() && p.current().indent >= indent {
+		parts = append(parts, p.current().text)
+		p.pos++
+	}
+	return p.parseScalar(line, strings.Join(parts, " "))
+}
+
+// parseMapping parses all keys and values of a mapping
+// with the given indentation.
+func (p *yamlParser) parseMapping(indent int) error {
+	for p.skip() {
+		line := p.current()
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return p.error(line, "unexpected indentation")
+		}
+		if isYAMLSequenceItem(line.text) {
+			return p.error(line, "sequence item inside mapping")
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return p.error(line, "expected mapping key")
+		}
+		if err := p.enc.openTag(sourceKey(key)); err != nil {
+			return err
+		}
+		p.pos++
+		if err := p.parseValue(line, indent, rest, true); err != nil {
+			return err
+		}
+		p.enc.closeTag()
+	}
+	return nil
+}
+
+// parseSequence parses all items of a sequence with
+// the given indentation.
+func (p *yamlParser) parseSequence(indent int) error {
+	for index := 0; p.skip(); index++ {
+		line := p.current()
+		if line.indent < indent || !isYAMLSequenceItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return p.error(line, "unexpected indentation")
+		}
+		p.enc.openTag(strconv.Itoa(index))
+		item := strings.TrimLeft(line.text[1:], " ")
+		_, _, isKey := splitYAMLKey(item)
+		if isKey || isYAMLSequenceItem(item) {
+			// Item is a compact nested mapping or sequence, so the
+			// line continues as block with a deeper indentation.
+			itemIndent := indent + len(line.text) - len(item)
+			p.lines[p.pos] = yamlLine{
+				number: line.number,
+				indent: itemIndent,
+				text:   item,
+				raw:    line.raw,
+			}
+			if err := p.parseBlock(itemIndent); err != nil {
+				return err
+			}
+		} else {
+			p.pos++
+			if err := p.parseValue(line, indent, item, false); err != nil {
+				return err
+			}
+		}
+		p.enc.closeTag()
+	}
+	return nil
+}
+
+// parseValue parses the value following a mapping key
+// or a sequence item indicator.
+func (p *yamlParser) parseValue(line yamlLine, indent int, value string, inMapping bool) error {
+	if value == "" {
+		if !p.skip() {
+			return nil
+		}
+		next := p.current()
+		if next.indent > indent {
+			return p.parseBlock(next.indent)
+		}
+		if inMapping && next.indent == indent && isYAMLSequenceItem(next.text) {
+			return p.parseSequence(indent)
+		}
+		return nil
+	}
+	switch value[0] {
+	case '&', '*':
+		return p.error(line, "anchors and aliases are not supported")
+	case '!':
+		return p.error(line, "tags are not supported")
+	case '|', '>':
+		return p.parseBlockScalar(line, indent, value)
+	case '[', '{':
+		return p.parseFlow(line, value)
+	}
+	return p.parseScalar(line, value)
+}
+
+// parseScalar writes a plain or quoted scalar.
+func (p *yamlParser) parseScalar(line yamlLine, value string) error {
+	text, null, err := unquoteYAMLScalar(value)
+	if err != nil {
+		return p.error(line, err.Error())
+	}
+	if !null {
+		p.enc.text(text)
+	}
+	return nil
+}
+
+// parseBlockScalar reads a literal or folded block scalar
+// out of the raw lines following the current one.
+func (p *yamlParser) parseBlockScalar(line yamlLine, indent int, header string) error {
+	if strings.Trim(header[1:], "+-") != "" {
+		return p.error(line, "invalid block scalar header")
+	}
+	var parts []string
+	contentIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos].raw
+		if strings.TrimSpace(raw) == "" {
+			parts = append(parts, "")
+			continue
+		}
+		rawIndent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if contentIndent < 0 {
+			contentIndent = rawIndent
+		}
+		if rawIndent <= indent || rawIndent < contentIndent {
+			break
+		}
+		parts = append(parts, raw[contentIndent:])
+	}
+	text := strings.Join(parts, "\n")
+	if header[0] == '>' {
+		text = foldYAMLLines(parts)
+	}
+	p.enc.text(text)
+	return nil
+}
+
+// parseFlow parses a flow sequence or mapping, which has
+// to be complete on one line.
+func (p *yamlParser) parseFlow(line yamlLine, value string) error {
+	f := &yamlFlow{
+		text: value,
+		enc:  p.enc,
+	}
+	if err := f.parseValue(); err != nil {
+		return p.error(line, err.Error())
+	}
+	f.skipSpaces()
+	if f.pos < len(f.text) {
+		return p.error(line, "unexpected characters after flow collection")
+	}
+	return nil
+}
+
+// error creates a conversion error for the given line.
+func (p *yamlParser) error(line yamlLine, msg string) error {
+	return errors.New(ErrCannotConvertSource, errorMessages, "YAML", fmt.Sprintf("line %d: %s", line.number, msg))
+}
+
+//--------------------
+// YAML FLOW PARSER
+//--------------------
+
+// yamlFlow parses flow collections like "[a, b]" or "{a: 1, b: 2}".
+type yamlFlow struct {
+	text string
+	pos  int
+	enc  *smlEncoder
+}
+
+// parseValue parses the next collection or scalar.
+func (f *yamlFlow) parseValue() error {
+	f.skipSpaces()
+	if f.pos >= len(f.text) {
+		return fmt.Errorf("unterminated flow collection")
+	}
+	switch f.text[f.pos] {
+	case '[':
+		return f.parseSequence()
+	case '{':
+		return f.parseMapping()
+	case '&', '*':
+		return fmt.Errorf("anchors and aliases are not supported")
+	case '!':
+		return fmt.Errorf("tags are not supported")
+	}
+	scalar, err := f.scanScalar()
+	if err != nil {
+		return err
+	}
+	text, null, err := unquoteYAMLScalar(scalar)
+	if err != nil {
+		return err
+	}
+	if !null {
+		f.enc.text(text)
+	}
+	return nil
+}
+
+// parseSequence parses a flow sequence.
+func (f *yamlFlow) parseSequence() error {
+	f.pos++
+	for index := 0; ; index++ {
+		f.skipSpaces()
+		if f.pos < len(f.text) && f.text[f.pos] == ']' {
+			f.pos++
+			return nil
+		}
+		f.enc.openTag(strconv.Itoa(index))
+		if err := f.parseValue(); err != nil {
+			return err
+		}
+		f.enc.closeTag()
+		if err := f.parseSeparator(']'); err != nil {
+			return err
+		}
+	}
+}
+
+// parseMapping parses a flow mapping.
+func (f *yamlFlow) parseMapping() error {
+	f.pos++
+	for {
+		f.skipSpaces()
+		if f.pos < len(f.text) && f.text[f.pos] == '}' {
+			f.pos++
+			return nil
+		}
+		scalar, err := f.scanScalar()
+		if err != nil {
+			return err
+		}
+		key, _, err := unquoteYAMLScalar(scalar)
+		if err != nil {
+			return err
+		}
+		if err = f.enc.openTag(sourceKey(key)); err != nil {
+			return err
+		}
+		f.skipSpaces()
+		if f.pos < len(f.text) && f.text[f.pos] == ':' {
+			f.pos++
+			f.skipSpaces()
+			if f.pos < len(f.text) && f.text[f.pos] != ',' && f.text[f.pos] != '}' {
+				if err = f.parseValue(); err != nil {
+					return err
+				}
+			}
+		}
+		f.enc.closeTag()
+		if err = f.parseSeparator('}'); err != nil {
+			return err
+		}
+	}
+}
+
+// parseSeparator reads a comma or leaves the closing
+// delimiter for the collection parser.
+func (f *yamlFlow) parseSeparator(closing byte) error {
+	f.skipSpaces()
+	if f.pos >= len(f.text) {
+		return fmt.Errorf("unterminated flow collection")
+	}
+	switch f.text[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case closing:
+		return nil
+	}
+	return fmt.Errorf("unexpected character %q in flow collection", f.text[f.pos])
+}
+
+// scanScalar returns the raw text of the next scalar.
+func (f *yamlFlow) scanScalar() (string, error) {
+	start := f.pos
+	if f.pos < len(f.text) && (f.text[f.pos] == '"' || f.text[f.pos] == '\'') {
+		end := scanYAMLQuoted(f.text, f.pos)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted scalar")
+		}
+		f.pos = end
+		return f.text[start:end], nil
+	}
+	for f.pos < len(f.text) && !strings.ContainsRune(",[]{}", rune(f.text[f.pos])) {
+		if f.text[f.pos] == ':' && (f.pos+1 == len(f.text) || strings.ContainsRune(" ,]}", rune(f.text[f.pos+1]))) {
+			break
+		}
+		f.pos++
+	}
+	return strings.TrimSpace(f.text[start:f.pos]), nil
+}
+
+// skipSpaces moves behind the following spaces.
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+//--------------------
+// YAML HELPERS
+//--------------------
+
+// isYAMLSequenceItem checks if the text starts a sequence item.
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a text into mapping key and value.
+func splitYAMLKey(text string) (string, string, bool) {
+	end := 0
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end = scanYAMLQuoted(text, 0)
+		if end < 0 {
+			return "", "", false
+		}
+	}
+	for i := end; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key, _, err := unquoteYAMLScalar(strings.TrimSpace(text[:i]))
+			if err != nil || key == "" {
+				return "", "", false
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+		if end > 0 {
+			// Quoted keys have to be followed by the colon.
+			if text[i] != ' ' {
+				return "", "", false
+			}
+		}
+	}
+	return "", "", false
+}
+
+// unquoteYAMLScalar returns the value of a plain or quoted
+// scalar and if it is null.
+func unquoteYAMLScalar(scalar string) (string, bool, error) {
+	if scalar == "" {
+		return "", false, nil
+	}
+	switch scalar[0] {
+	case '"':
+		if scanYAMLQuoted(scalar, 0) != len(scalar) {
+			return "", false, fmt.Errorf("invalid double quoted scalar")
+		}
+		text, err := strconv.Unquote(scalar)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid double quoted scalar")
+		}
+		return text, false, nil
+	case '\'':
+		if scanYAMLQuoted(scalar, 0) != len(scalar) {
+			return "", false, fmt.Errorf("invalid single quoted scalar")
+		}
+		return strings.Replace(scalar[1:len(scalar)-1], "''", "'", -1), false, nil
+	}
+	switch scalar {
+	case "~", "null", "Null", "NULL":
+		return "", true, nil
+	}
+	return scalar, false, nil
+}
+
+// scanYAMLQuoted returns the position behind the quoted scalar
+// starting at the given position or -1 if it is not terminated.
+func scanYAMLQuoted(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment outside
+// of quoted scalars.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.ContainsRune(" [{,:", rune(text[i-1]))):
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return strings.TrimRight(text, " \t")
+}
+
+// foldYAMLLines joins the lines of a folded block scalar. Single
+// line breaks become spaces, empty lines are kept as breaks.
+func foldYAMLLines(lines []string) string {
+	var folded []string
+	var current []string
+	for _, line := range lines {
+		if line == "" {
+			if current != nil {
+				folded = append(folded, strings.Join(current, " "))
+				current = nil
+			}
+			folded = append(folded, "")
+			continue
+		}
+		current = append(current, line)
+	}
+	if current != nil {
+		folded = append(folded, strings.Join(current, " "))
+	}
+	var buf []string
+	for i, part := range folded {
+		if part == "" && i > 0 && folded[i-1] != "" {
+			continue
+		}
+		buf = append(buf, part)
+	}
+	return strings.Join(buf, "\n")
+}
+
+// EOF
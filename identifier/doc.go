@@ -9,6 +9,8 @@
 // to produce identifiers out of diffent input data as well as UUIDs.
 //
 // The UUID generation can be done according the versions 1, 3, 4, and 5.
+// UUIDs can be parsed with ParseUUID(). Sortable IDs created with
+// NewSortableID() are ordered by their creation time like ULIDs.
 // Other identifier types are based on passed data or types. Here
 // the individual parts are harmonized and concatenated by the
 // passed seperators. It is the users responsibility to check if
@@ -22,11 +22,13 @@ import (
 const (
 	ErrInvalidHexLength = iota + 1
 	ErrInvalidHexValue
+	ErrInvalidUUID
 )
 
 var errorMessages = errors.Messages{
 	ErrInvalidHexLength: "invalid length of hex string, has to be 32",
 	ErrInvalidHexValue:  "invalid value of hex string",
+	ErrInvalidUUID:      "invalid UUID %q",
 }
 
 //--------------------
@@ -45,4 +47,10 @@ func IsInvalidHexValueError(err error) bool {
 	return errors.IsError(err, ErrInvalidHexValue)
 }
 
+// IsInvalidUUIDError returns true, if the error signals that
+// a string cannot be parsed as UUID.
+func IsInvalidUUIDError(err error) bool {
+	return errors.IsError(err, ErrInvalidUUID)
+}
+
 // EOF
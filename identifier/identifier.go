@@ -27,7 +27,11 @@ import (
 // all as lowercase strings and concatenated with the separator
 // Non letters and digits are exchanged with dashes and
 // reduced to a maximum of one each. If limit is true only
-// 'a' to 'z' and '0' to '9' are allowed.
+// 'a' to 'z' and '0' to '9' are allowed. So in this case
+// a separator contained in a part is replaced by a dash too
+// and cannot clash with the separators between the parts,
+// as long as neither letters, digits, nor a dash are used
+// as separator. Without limit parts are taken as they are.
 func LimitedSepIdentifier(sep string, limit bool, parts ...interface{}) string {
 	iparts := make([]string, 0)
 	for _, p := range parts {
@@ -57,13 +61,16 @@ func LimitedSepIdentifier(sep string, limit bool, parts ...interface{}) string {
 // SepIdentifier builds an identifier out of multiple parts, all
 // as lowercase strings and concatenated with the separator
 // Non letters and digits are exchanged with dashes and
-// reduced to a maximum of one each.
+// reduced to a maximum of one each. This way separators
+// inside of parts are escaped, e.g. the parts "a/b" and "c"
+// with the separator "/" lead to "a-b/c".
 func SepIdentifier(sep string, parts ...interface{}) string {
 	return LimitedSepIdentifier(sep, true, parts...)
 }
 
 // Identifier works like SepIdentifier but the seperator
-// is set to be a colon.
+// is set to be a colon. Colons inside of parts are exchanged
+// with dashes. Use SepIdentifier for other separators.
 func Identifier(parts ...interface{}) string {
 	return SepIdentifier(":", parts...)
 }
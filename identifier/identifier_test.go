@@ -46,6 +46,10 @@ func TestIdentifier(t *testing.T) {
 
 	id = identifier.Identifier(2011, 6, 22, "One, two, or  three things.")
 	assert.Equal(id, "2011:6:22:one-two-or-three-things", "wrong Identifier() result")
+
+	// Parts containing the separator.
+	id = identifier.Identifier("cell", "my:behavior", "::")
+	assert.Equal(id, "cell:my-behavior", "wrong Identifier() result")
 }
 
 // Test the creation of identifiers based on parts with defined seperators.
@@ -60,6 +64,9 @@ func TestSepIdentifier(t *testing.T) {
 
 	id = identifier.LimitedSepIdentifier("+", true, "     ", 1, "oNe", 2, "TWO", "3", "ÄÖÜ", "Four", "+#-:,")
 	assert.Equal(id, "1+one+2+two+3+four", "wrong LimitedSepIdentifier() result")
+
+	id = identifier.SepIdentifier("/", "a/b", "c")
+	assert.Equal(id, "a-b/c", "wrong SepIdentifier() result")
 }
 
 //--------------------
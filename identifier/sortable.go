@@ -0,0 +1,117 @@
+// Tideland Go Library - Identifier - Sortable ID
+//
+// Copyright (C) 2009-2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package identifier
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+//--------------------
+// SORTABLE ID
+//--------------------
+
+// crockfordAlphabet is the base32 alphabet of Douglas Crockford
+// used for the string representation of sortable IDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// SortableID is an identifier with 16 bytes ordered by creation
+// time like a ULID. The first 6 bytes contain the milliseconds since
+// the Unix epoch, the following 10 bytes a random part.
+type SortableID [16]byte
+
+// sortableGenerator creates monotonic sortable IDs.
+type sortableGenerator struct {
+	sync.Mutex
+	millis uint64
+	random [10]byte
+}
+
+// sortables is the generator used by NewSortableID.
+var sortables = &sortableGenerator{}
+
+// NewSortableID generates a new sortable ID. IDs created during the
+// same millisecond get the random part of the previous one increased
+// by one. So their order matches the order of creation, also for
+// their string representation.
+func NewSortableID() SortableID {
+	sg := sortables
+	sg.Lock()
+	defer sg.Unlock()
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	if now > sg.millis {
+		sg.millis = now
+		sg.newRandom()
+	} else if !sg.incrRandom() {
+		// Overflow of the random part, so continue
+		// with the next millisecond.
+		sg.millis++
+		sg.newRandom()
+	}
+	id := SortableID{}
+	var millis [8]byte
+	binary.BigEndian.PutUint64(millis[:], sg.millis)
+	copy(id[0:6], millis[2:8])
+	copy(id[6:16], sg.random[:])
+	return id
+}
+
+// Time returns the creation time of the ID with
+// millisecond precision.
+func (id SortableID) Time() time.Time {
+	var millis [8]byte
+	copy(millis[2:8], id[0:6])
+	ms := int64(binary.BigEndian.Uint64(millis[:]))
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// String returns the ID as 26 characters in Crockford's base32.
+// Their lexical order matches the order of the IDs.
+func (id SortableID) String() string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	out := make([]byte, 26)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+//--------------------
+// PRIVATE HELPERS
+//--------------------
+
+// newRandom fills the random part of the generator.
+func (sg *sortableGenerator) newRandom() {
+	if _, err := rand.Read(sg.random[:]); err != nil {
+		// Panic like NewUUID.
+		panic(err)
+	}
+}
+
+// incrRandom increases the random part of the generator by one.
+// It returns false in case of an overflow.
+func (sg *sortableGenerator) incrRandom() bool {
+	for i := len(sg.random) - 1; i >= 0; i-- {
+		sg.random[i]++
+		if sg.random[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF
@@ -0,0 +1,53 @@
+// Tideland Go Library - Identifier - Unit Tests
+//
+// Copyright (C) 2009-2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package identifier_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tideland/golib/audit"
+	"github.com/tideland/golib/identifier"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// Test the sortable IDs.
+func TestSortableID(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	// Asserts.
+	before := time.Now().Truncate(time.Millisecond)
+	id := identifier.NewSortableID()
+	after := time.Now()
+	assert.True(!id.Time().Before(before) && !id.Time().After(after), "time of the ID")
+	assert.Match(id.String(), "[0-9A-HJKMNP-TV-Z]{26}")
+	// Generate many IDs, a lot inside the same millisecond.
+	ids := make([]string, 100000)
+	unique := make(map[string]bool)
+	for i := range ids {
+		ids[i] = identifier.NewSortableID().String()
+		assert.False(unique[ids[i]], "sortable ID collision must not happen")
+		unique[ids[i]] = true
+		if i%10000 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+	sort.Strings(sorted)
+	assert.Equal(sorted, ids, "IDs have to be sorted chronologically")
+}
+
+// EOF
@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/tideland/golib/errors"
@@ -28,6 +29,9 @@ import (
 // UUID
 //--------------------
 
+// Versions and variants of UUIDs. The variant is the value of
+// the three most significant bits of byte 8, the bits following
+// the significant ones for a variant are not taken into account.
 const (
 	UUIDv1 byte = 1
 	UUIDv3 byte = 3
@@ -44,7 +48,8 @@ const (
 // See http://en.wikipedia.org/wiki/Universally_unique_identifier.
 type UUID [16]byte
 
-// NewUUID returns a new UUID with based on the default version 4.
+// NewUUID returns a new UUID based on the default version 4,
+// see NewUUIDv4.
 func NewUUID() UUID {
 	uuid, err := NewUUIDv4()
 	if err != nil {
@@ -95,7 +100,9 @@ func NewUUIDv3(ns UUID, name []byte) (UUID, error) {
 	return uuid, nil
 }
 
-// NewUUIDv4 generates a new UUID based on version 4 (strong random number).
+// NewUUIDv4 generates a new UUID based on version 4 (strong random number)
+// as defined in RFC 4122. The random numbers are read from crypto/rand,
+// the version nibble is set to 4 and the variant bits to 10.
 func NewUUIDv4() (UUID, error) {
 	uuid := UUID{}
 	_, err := rand.Read([]byte(uuid[:]))
@@ -109,7 +116,9 @@ func NewUUIDv4() (UUID, error) {
 }
 
 // NewUUIDv5 generates a new UUID based on version 5 (SHA1 hash of a namespace
-// and a name).
+// and a name). The same namespace and name always lead to the same UUID.
+// Predefined namespaces are returned by UUIDNamespaceDNS, UUIDNamespaceURL,
+// UUIDNamespaceOID, and UUIDNamespaceX500.
 func NewUUIDv5(ns UUID, name []byte) (UUID, error) {
 	uuid := UUID{}
 	hash := sha1.New()
@@ -137,13 +146,50 @@ func NewUUIDByHex(source string) (UUID, error) {
 	return uuid, nil
 }
 
+// ParseUUID parses the string representation of a UUID as returned
+// by String. Additionally the braced form "{...}" and the URN form
+// "urn:uuid:..." are accepted. Hex digits may be uppercase.
+func ParseUUID(source string) (UUID, error) {
+	uuid := UUID{}
+	s := source
+	switch {
+	case len(s) > 9 && strings.EqualFold(s[:9], "urn:uuid:"):
+		s = s[9:]
+	case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+		s = s[1 : len(s)-1]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, errors.New(ErrInvalidUUID, errorMessages, source)
+	}
+	raw, err := hex.DecodeString(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36])
+	if err != nil {
+		return uuid, errors.Annotate(err, ErrInvalidUUID, errorMessages, source)
+	}
+	copy(uuid[:], raw)
+	return uuid, nil
+}
+
+// IsValidUUID checks if the string can be parsed with ParseUUID.
+func IsValidUUID(source string) bool {
+	_, err := ParseUUID(source)
+	return err == nil
+}
+
 // Version returns the version number of the UUID algorithm.
 func (uuid UUID) Version() byte {
 	return uuid[6] & 0xf0 >> 4
 }
 
-// Variant returns the variant of the UUID.
+// Variant returns the variant of the UUID. Only the significant
+// bits are interpreted, so e.g. each UUID starting byte 8 with
+// the bits 10 returns UUIDVariantRFC4122.
 func (uuid UUID) Variant() byte {
+	switch {
+	case uuid[8]&0x80 == 0x00:
+		return UUIDVariantNCS
+	case uuid[8]&0xc0 == 0x80:
+		return UUIDVariantRFC4122
+	}
 	return uuid[8] & 0xe0 >> 5
 }
 
@@ -185,9 +231,16 @@ func (uuid *UUID) setVersion(v byte) {
 	uuid[6] = (uuid[6] & 0x0f) | (v << 4)
 }
 
-// setVariant sets the variant part of the UUID.
+// setVariant sets the significant bits of the variant part of the UUID.
 func (uuid *UUID) setVariant(v byte) {
-	uuid[8] = (uuid[8] & 0x1f) | (v << 5)
+	switch v {
+	case UUIDVariantNCS:
+		uuid[8] = uuid[8] & 0x7f
+	case UUIDVariantRFC4122:
+		uuid[8] = (uuid[8] & 0x3f) | 0x80
+	default:
+		uuid[8] = (uuid[8] & 0x1f) | (v << 5)
+	}
 }
 
 // UUIDNamespaceDNS returns the DNS namespace UUID.
@@ -77,6 +77,119 @@ func TestUUIDVersions(t *testing.T) {
 	assert.Logf("UUID V5: %v", uuidV5)
 }
 
+// Test the version and variant bits of version 4 UUIDs.
+func TestUUIDv4Bits(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	// Asserts.
+	for i := 0; i < 1000; i++ {
+		uuid, err := identifier.NewUUIDv4()
+		assert.Nil(err)
+		assert.Equal(uuid[6]&0xf0, byte(0x40), "version nibble has to be 4")
+		assert.Equal(uuid[8]&0xc0, byte(0x80), "variant bits have to be 10")
+		assert.Equal(uuid.Version(), identifier.UUIDv4)
+		assert.Equal(uuid.Variant(), identifier.UUIDVariantRFC4122)
+		assert.Match(uuid.String(), "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}")
+	}
+	// Variants of foreign UUIDs.
+	variants := map[string]byte{
+		"6ba7b8109dad11d100b400c04fd430c8": identifier.UUIDVariantNCS,
+		"6ba7b8109dad11d180b400c04fd430c8": identifier.UUIDVariantRFC4122,
+		"6ba7b8109dad11d1b0b400c04fd430c8": identifier.UUIDVariantRFC4122,
+		"6ba7b8109dad11d1c0b400c04fd430c8": identifier.UUIDVariantMicrosoft,
+		"6ba7b8109dad11d1e0b400c04fd430c8": identifier.UUIDVariantFuture,
+	}
+	for hex, variant := range variants {
+		uuid, err := identifier.NewUUIDByHex(hex)
+		assert.Nil(err)
+		assert.Equal(uuid.Variant(), variant, hex)
+	}
+}
+
+// Test name based UUIDs against known vectors.
+func TestUUIDNameBased(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	tests := []struct {
+		version byte
+		ns      identifier.UUID
+		name    string
+		uuid    string
+	}{
+		// Examples of RFC 4122 (with errata) and RFC 9562.
+		{identifier.UUIDv3, identifier.UUIDNamespaceDNS(), "www.widgets.com", "3d813cbb-47fb-32ba-91df-831e1593ac29"},
+		{identifier.UUIDv3, identifier.UUIDNamespaceDNS(), "www.example.com", "5df41881-3aed-3515-88a7-2f4a814cf09e"},
+		{identifier.UUIDv5, identifier.UUIDNamespaceDNS(), "www.example.com", "2ed6657d-e927-568b-95e1-2665a8aea6a2"},
+		// Cross-checked with other implementations.
+		{identifier.UUIDv5, identifier.UUIDNamespaceDNS(), "python.org", "886313e1-3b8a-5372-9b90-0c9aee199e5d"},
+		{identifier.UUIDv5, identifier.UUIDNamespaceURL(), "https://tideland.biz/", "99fe895a-a559-5be6-b994-511ad98389cd"},
+		{identifier.UUIDv5, identifier.UUIDNamespaceOID(), "1.3.6.1", "1447fa61-5277-5fef-a9b3-fbc6e44f4af3"},
+	}
+	for _, test := range tests {
+		var uuid identifier.UUID
+		var err error
+		if test.version == identifier.UUIDv3 {
+			uuid, err = identifier.NewUUIDv3(test.ns, []byte(test.name))
+		} else {
+			uuid, err = identifier.NewUUIDv5(test.ns, []byte(test.name))
+		}
+		assert.Nil(err)
+		assert.Equal(uuid.String(), test.uuid, test.name)
+		assert.Equal(uuid.Version(), test.version)
+		assert.Equal(uuid.Variant(), identifier.UUIDVariantRFC4122)
+	}
+	// Same input always leads to the same UUID.
+	uuidA, err := identifier.NewUUIDv5(identifier.UUIDNamespaceURL(), []byte("tenant/resource"))
+	assert.Nil(err)
+	uuidB, err := identifier.NewUUIDv5(identifier.UUIDNamespaceURL(), []byte("tenant/resource"))
+	assert.Nil(err)
+	assert.Equal(uuidA, uuidB)
+	uuidC, err := identifier.NewUUIDv5(identifier.UUIDNamespaceDNS(), []byte("tenant/resource"))
+	assert.Nil(err)
+	assert.Different(uuidA, uuidC)
+}
+
+// Test parsing UUIDs.
+func TestParseUUID(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	// Valid forms.
+	uuid := identifier.NewUUID()
+	parsed, err := identifier.ParseUUID(uuid.String())
+	assert.Nil(err)
+	assert.Equal(parsed, uuid)
+	assert.Equal(parsed.String(), uuid.String())
+	valids := []string{
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"6BA7B810-9DAD-11D1-80B4-00C04FD430C8",
+		"{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+		"urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"URN:UUID:6BA7B810-9dad-11d1-80b4-00c04fd430c8",
+	}
+	for _, valid := range valids {
+		parsed, err = identifier.ParseUUID(valid)
+		assert.Nil(err, valid)
+		assert.Equal(parsed, identifier.UUIDNamespaceDNS(), valid)
+		assert.Equal(parsed.String(), "6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+		assert.True(identifier.IsValidUUID(valid), valid)
+	}
+	// Invalid forms.
+	invalids := []string{
+		"",
+		"6ba7b8109dad11d180b400c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8a",
+		"6ba7b810x9dad-11d1-80b4-00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cz",
+		"{6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"urn:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	}
+	for _, invalid := range invalids {
+		_, err = identifier.ParseUUID(invalid)
+		assert.True(identifier.IsInvalidUUIDError(err), invalid)
+		assert.False(identifier.IsValidUUID(invalid), invalid)
+	}
+	_, err = identifier.ParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430cz")
+	assert.ErrorMatch(err, `\[IDENTIFIER:.*\] invalid UUID "6ba7b810-9dad-11d1-80b4-00c04fd430cz": .*`)
+}
+
 // Test creating UUIDs from hex strings.
 func TestUUIDByHex(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -7,6 +7,47 @@
 
 // The Tideland Go Library logger package provides a flexible way
 // to log information with different levels and on different backends.
+//
+// Beside the printf-style functions like Infof() there are functions
+// like Infow() logging a message with structured fields, passed as
+// alternating keys and values.
+//
+//    logger.Infow("event processed", "cell", cellID, "topic", topic)
+//
+// Backends implementing StructuredLogger, like the standard logger,
+// receive the fields as part of an Entry. Its Formatter renders them,
+// by default as key=value pairs. Other backends get them appended to
+// the message.
+//
+// With() returns a FieldLogger with bound fields, e.g. a trace ID. They
+// are logged in front of the fields of each call. Its With() method
+// creates children adding more fields, the fields of the parent stay
+// unchanged.
+//
+//    eventLog := logger.With("cell", cellID, "trace", traceID)
+//    eventLog.Infow("event received", "topic", topic)
+//
+// For machine-readable logs the formatter of the standard logger can be
+// changed to JSONFormatter{}. Every entry then is written as a JSON
+// object containing time, level, info, message, and the fields.
+//
+//    logger.SetFormatter(logger.JSONFormatter{})
+//
+// The destination of the standard logger is set with SetWriter(). Here
+// MultiWriter() helps to write to multiple destinations at once. With
+// SetLevelWriter() a level and those above it can be routed to an own
+// writer.
+//
+//    logger.SetWriter(logger.MultiWriter(os.Stdout, logFile))
+//    logger.SetLevelWriter(logger.LevelError, os.Stderr)
+//
+// To avoid floods of identical messages a Sampler can be set. The one
+// created with NewRateSampler() logs at most a given number of identical
+// messages per interval. Only level, info, and message text are compared,
+// not the fields. The number of suppressed ones is logged when the
+// interval is over or the sampler is replaced.
+//
+//    logger.SetSampler(logger.NewRateSampler(10, time.Minute))
 package logger
 
 // EOF
@@ -0,0 +1,203 @@
+// Tideland Go Library - Logger - Field Logger
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+)
+
+//--------------------
+// FIELD LOGGER
+//--------------------
+
+// FieldLogger logs like the package functions but adds its bound
+// fields in front of the fields of each call.
+type FieldLogger interface {
+	// Debugf logs a message at debug level.
+	Debugf(format string, args ...interface{})
+
+	// Infof logs a message at info level.
+	Infof(format string, args ...interface{})
+
+	// Warningf logs a message at warning level.
+	Warningf(format string, args ...interface{})
+
+	// Errorf logs a message at error level.
+	Errorf(format string, args ...interface{})
+
+	// Criticalf logs a message at critical level.
+	Criticalf(format string, args ...interface{})
+
+	// Fatalf logs a message independant of any level and
+	// calls the fatal exiter function.
+	Fatalf(format string, args ...interface{})
+
+	// Debugw logs a message with structured fields at debug level.
+	Debugw(msg string, kv ...interface{})
+
+	// Infow logs a message with structured fields at info level.
+	Infow(msg string, kv ...interface{})
+
+	// Warningw logs a message with structured fields at warning level.
+	Warningw(msg string, kv ...interface{})
+
+	// Errorw logs a message with structured fields at error level.
+	Errorw(msg string, kv ...interface{})
+
+	// Criticalw logs a message with structured fields at critical level.
+	Criticalw(msg string, kv ...interface{})
+
+	// Fatalw logs a message with structured fields independant
+	// of any level and calls the fatal exiter function.
+	Fatalw(msg string, kv ...interface{})
+
+	// With returns a child logger with the passed fields bound
+	// in addition to the ones of this logger.
+	With(kv ...interface{}) FieldLogger
+}
+
+// fieldLogger implements the FieldLogger interface. Its fields
+// are never changed, so it can be used concurrently.
+type fieldLogger struct {
+	fields []Field
+}
+
+// With returns a logger with the passed fields, alternating keys
+// and values, bound. They are logged with every call.
+func With(kv ...interface{}) FieldLogger {
+	return &fieldLogger{
+		fields: Fields(kv...),
+	}
+}
+
+// Debugf is specified on the FieldLogger interface.
+func (fl *fieldLogger) Debugf(format string, args ...interface{}) {
+	if enabled(LevelDebug) {
+		fl.log(LevelDebug, retrieveCallInfo().verboseFormat(), fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Infof is specified on the FieldLogger interface.
+func (fl *fieldLogger) Infof(format string, args ...interface{}) {
+	if enabled(LevelInfo) {
+		fl.log(LevelInfo, retrieveCallInfo().shortFormat(), fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Warningf is specified on the FieldLogger interface.
+func (fl *fieldLogger) Warningf(format string, args ...interface{}) {
+	if enabled(LevelWarning) {
+		fl.log(LevelWarning, retrieveCallInfo().shortFormat(), fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Errorf is specified on the FieldLogger interface.
+func (fl *fieldLogger) Errorf(format string, args ...interface{}) {
+	if enabled(LevelError) {
+		fl.log(LevelError, retrieveCallInfo().shortFormat(), fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Criticalf is specified on the FieldLogger interface.
+func (fl *fieldLogger) Criticalf(format string, args ...interface{}) {
+	if enabled(LevelCritical) {
+		fl.log(LevelCritical, retrieveCallInfo().verboseFormat(), fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// Fatalf is specified on the FieldLogger interface.
+func (fl *fieldLogger) Fatalf(format string, args ...interface{}) {
+	fl.fatal(retrieveCallInfo().verboseFormat(), fmt.Sprintf(format, args...), nil)
+}
+
+// Debugw is specified on the FieldLogger interface.
+func (fl *fieldLogger) Debugw(msg string, kv ...interface{}) {
+	if enabled(LevelDebug) {
+		fl.log(LevelDebug, retrieveCallInfo().verboseFormat(), msg, kv)
+	}
+}
+
+// Infow is specified on the FieldLogger interface.
+func (fl *fieldLogger) Infow(msg string, kv ...interface{}) {
+	if enabled(LevelInfo) {
+		fl.log(LevelInfo, retrieveCallInfo().shortFormat(), msg, kv)
+	}
+}
+
+// Warningw is specified on the FieldLogger interface.
+func (fl *fieldLogger) Warningw(msg string, kv ...interface{}) {
+	if enabled(LevelWarning) {
+		fl.log(LevelWarning, retrieveCallInfo().shortFormat(), msg, kv)
+	}
+}
+
+// Errorw is specified on the FieldLogger interface.
+func (fl *fieldLogger) Errorw(msg string, kv ...interface{}) {
+	if enabled(LevelError) {
+		fl.log(LevelError, retrieveCallInfo().shortFormat(), msg, kv)
+	}
+}
+
+// Criticalw is specified on the FieldLogger interface.
+func (fl *fieldLogger) Criticalw(msg string, kv ...interface{}) {
+	if enabled(LevelCritical) {
+		fl.log(LevelCritical, retrieveCallInfo().verboseFormat(), msg, kv)
+	}
+}
+
+// Fatalw is specified on the FieldLogger interface.
+func (fl *fieldLogger) Fatalw(msg string, kv ...interface{}) {
+	fl.fatal(retrieveCallInfo().verboseFormat(), msg, kv)
+}
+
+// With is specified on the FieldLogger interface.
+func (fl *fieldLogger) With(kv ...interface{}) FieldLogger {
+	return &fieldLogger{
+		fields: fl.join(kv),
+	}
+}
+
+// log logs the message with the bound and the passed fields
+// if it's not filtered.
+func (fl *fieldLogger) log(level LogLevel, info, msg string, kv []interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if shallLog(level, info, msg) {
+		logFields(level, info, msg, fl.join(kv))
+	}
+}
+
+// fatal logs the message with the bound and the passed fields
+// and calls the fatal exiter.
+func (fl *fieldLogger) fatal(info, msg string, kv []interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	logFields(LevelFatal, info, msg, fl.join(kv))
+	logFatalExiter()
+}
+
+// join returns a new slice containing the bound
+// and the passed fields.
+func (fl *fieldLogger) join(kv []interface{}) []Field {
+	fields := make([]Field, 0, len(fl.fields)+(len(kv)+1)/2)
+	fields = append(fields, fl.fields...)
+	return append(fields, Fields(kv...)...)
+}
+
+// enabled checks if the passed level is logged.
+func enabled(level LogLevel) bool {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	return logLevel <= level
+}
+
+// EOF
@@ -0,0 +1,196 @@
+// Tideland Go Library - Logger - Format
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------
+// FIELDS
+//--------------------
+
+// badKey is used as key for a value without a key.
+const badKey = "!BADKEY"
+
+// Field is one key/value pair of a structured log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields creates fields out of alternating keys and values. Keys
+// which are no strings are converted, an odd number of arguments
+// lets the last value get the key "!BADKEY".
+func Fields(kv ...interface{}) []Field {
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			fields = append(fields, Field{badKey, kv[i]})
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		fields = append(fields, Field{key, kv[i+1]})
+	}
+	return fields
+}
+
+// formatFields renders the fields as space separated key=value
+// pairs. Values containing spaces, quotes, or equal signs are quoted.
+func formatFields(fields []Field) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		parts[i] = field.Key + "=" + value
+	}
+	return strings.Join(parts, " ")
+}
+
+//--------------------
+// ENTRY
+//--------------------
+
+// levelNames maps the log levels to their names in the output.
+var levelNames = map[LogLevel]string{
+	LevelDebug:    "DEBUG",
+	LevelInfo:     "INFO",
+	LevelWarning:  "WARNING",
+	LevelError:    "ERROR",
+	LevelCritical: "CRITICAL",
+	LevelFatal:    "FATAL",
+}
+
+// String implements the fmt.Stringer interface.
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Entry contains all information of one log entry.
+type Entry struct {
+	Time   time.Time
+	Level  LogLevel
+	Info   string
+	Msg    string
+	Fields []Field
+}
+
+// StructuredLogger is a logger backend able to handle complete
+// entries including their fields. Other backends get the fields
+// appended to the message.
+type StructuredLogger interface {
+	Logger
+
+	// LogEntry logs the passed entry.
+	LogEntry(entry *Entry)
+}
+
+//--------------------
+// FORMATTER
+//--------------------
+
+// Formatter renders an entry into one line of output
+// without the trailing newline.
+type Formatter interface {
+	Format(entry *Entry) string
+}
+
+// TextFormatter renders entries as plain text with the fields
+// as key=value pairs behind the message. It's the default of
+// the standard logger.
+type TextFormatter struct {
+	// TimeFormat is the layout of the timestamp. If empty
+	// "2006-01-02 15:04:05 Z07:00" is used.
+	TimeFormat string
+}
+
+// Format implements the Formatter interface.
+func (tf TextFormatter) Format(entry *Entry) string {
+	timeFormat := tf.TimeFormat
+	if timeFormat == "" {
+		timeFormat = defaultTimeFormat
+	}
+	line := entry.Time.Format(timeFormat) + " [" + entry.Level.String() + "] " + entry.Info + " " + entry.Msg
+	if len(entry.Fields) > 0 {
+		line += " " + formatFields(entry.Fields)
+	}
+	return line
+}
+
+// JSONFormatter renders entries as JSON objects with the keys "time",
+// "level", "info", and "message" followed by the fields. Fields with
+// one of these keys get the prefix "field.".
+type JSONFormatter struct {
+	// TimeFormat is the layout of the timestamp. If empty
+	// time.RFC3339 is used.
+	TimeFormat string
+}
+
+// Format implements the Formatter interface.
+func (jf JSONFormatter) Format(entry *Entry) string {
+	timeFormat := jf.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339
+	}
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	writeJSONPair(&buf, "time", entry.Time.Format(timeFormat))
+	buf.WriteString(",")
+	writeJSONPair(&buf, "level", entry.Level.String())
+	buf.WriteString(",")
+	writeJSONPair(&buf, "info", entry.Info)
+	buf.WriteString(",")
+	writeJSONPair(&buf, "message", entry.Msg)
+	for _, field := range entry.Fields {
+		key := field.Key
+		switch key {
+		case "time", "level", "info", "message":
+			key = "field." + key
+		}
+		buf.WriteString(",")
+		writeJSONPair(&buf, key, field.Value)
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// writeJSONPair writes a key and its value in JSON notation. Errors
+// are written as their message, values which cannot be marshalled
+// as their default format.
+func writeJSONPair(buf *bytes.Buffer, key string, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(k)
+	buf.WriteString(":")
+	buf.Write(v)
+}
+
+// EOF
@@ -71,6 +71,7 @@ var (
 	logLevel       LogLevel        = LevelInfo
 	logFatalExiter FatalExiterFunc = OsFatalExiter
 	logFilter      FilterFunc
+	logSampler     Sampler
 )
 
 // Level returns the current log level.
@@ -153,6 +154,21 @@ func UnsetFilter() FilterFunc {
 	return current
 }
 
+// SetSampler sets the global sampler reducing the output of
+// identical messages and returns the current one. The suppressions
+// not reported so far by the current one are logged. A nil sampler
+// logs all messages again.
+func SetSampler(s Sampler) Sampler {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	current := logSampler
+	logSampler = s
+	if current != nil {
+		logSuppressions(current.Flush())
+	}
+	return current
+}
+
 //--------------------
 // LOGGING
 //--------------------
@@ -241,6 +257,88 @@ func Fatalf(format string, args ...interface{}) {
 	logFatalExiter()
 }
 
+// Debugw logs a message with structured fields at debug level.
+// The fields are passed as alternating keys and values.
+func Debugw(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logLevel <= LevelDebug {
+		info := retrieveCallInfo().verboseFormat()
+
+		if shallLog(LevelDebug, info, msg) {
+			logFields(LevelDebug, info, msg, Fields(kv...))
+		}
+	}
+}
+
+// Infow logs a message with structured fields at info level.
+// The fields are passed as alternating keys and values.
+func Infow(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logLevel <= LevelInfo {
+		info := retrieveCallInfo().shortFormat()
+
+		if shallLog(LevelInfo, info, msg) {
+			logFields(LevelInfo, info, msg, Fields(kv...))
+		}
+	}
+}
+
+// Warningw logs a message with structured fields at warning level.
+// The fields are passed as alternating keys and values.
+func Warningw(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logLevel <= LevelWarning {
+		info := retrieveCallInfo().shortFormat()
+
+		if shallLog(LevelWarning, info, msg) {
+			logFields(LevelWarning, info, msg, Fields(kv...))
+		}
+	}
+}
+
+// Errorw logs a message with structured fields at error level.
+// The fields are passed as alternating keys and values.
+func Errorw(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logLevel <= LevelError {
+		info := retrieveCallInfo().shortFormat()
+
+		if shallLog(LevelError, info, msg) {
+			logFields(LevelError, info, msg, Fields(kv...))
+		}
+	}
+}
+
+// Criticalw logs a message with structured fields at critical level.
+// The fields are passed as alternating keys and values.
+func Criticalw(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logLevel <= LevelCritical {
+		info := retrieveCallInfo().verboseFormat()
+
+		if shallLog(LevelCritical, info, msg) {
+			logFields(LevelCritical, info, msg, Fields(kv...))
+		}
+	}
+}
+
+// Fatalw logs a message with structured fields independant of
+// any level. After logging the message the functions calls the
+// fatal exiter function like Fatalf().
+func Fatalw(msg string, kv ...interface{}) {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	info := retrieveCallInfo().verboseFormat()
+
+	logFields(LevelFatal, info, msg, Fields(kv...))
+	logFatalExiter()
+}
+
 //--------------------
 // LOGGER
 //--------------------
@@ -274,6 +372,56 @@ func SetLogger(l Logger) {
 	logBackend = l
 }
 
+// formattable is implemented by backends with an exchangeable
+// formatter like the standard logger.
+type formattable interface {
+	SetFormatter(formatter Formatter) Formatter
+}
+
+// SetFormatter sets the formatter of the current backend, e.g.
+// JSONFormatter{}, and returns the current one. It has no effect
+// on backends without a formatter, here nil is returned.
+func SetFormatter(formatter Formatter) Formatter {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if f, ok := logBackend.(formattable); ok {
+		return f.SetFormatter(formatter)
+	}
+	return nil
+}
+
+// writable is implemented by backends with exchangeable
+// writers like the standard logger.
+type writable interface {
+	SetWriter(out io.Writer) io.Writer
+	SetLevelWriter(level LogLevel, out io.Writer) io.Writer
+}
+
+// SetWriter sets the writer of the current backend and returns
+// the current one. It has no effect on backends without a writer,
+// here nil is returned.
+func SetWriter(out io.Writer) io.Writer {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if w, ok := logBackend.(writable); ok {
+		return w.SetWriter(out)
+	}
+	return nil
+}
+
+// SetLevelWriter sets the writer of the current backend for the
+// passed level and above, e.g. to route errors to a separate stream.
+// It returns the current one. A nil writer removes the routing. It
+// has no effect on backends without a writer, here nil is returned.
+func SetLevelWriter(level LogLevel, out io.Writer) io.Writer {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if w, ok := logBackend.(writable); ok {
+		return w.SetLevelWriter(level, out)
+	}
+	return nil
+}
+
 // defaultTimeFormat controls how the timestamp of the standard
 // logger is printed by default.
 const defaultTimeFormat = "2006-01-02 15:04:05 Z07:00"
@@ -281,18 +429,26 @@ const defaultTimeFormat = "2006-01-02 15:04:05 Z07:00"
 // StandardLogger is a simple logger writing to the given writer. Beside
 // the output it doesn't handle the levels differently.
 type StandardLogger struct {
-	mutex      sync.Mutex
-	out        io.Writer
-	timeFormat string
+	mutex     sync.Mutex
+	out       io.Writer
+	levelOuts map[LogLevel]io.Writer
+	formatter Formatter
+}
+
+// NewFormatterLogger creates a logger writing to the passed
+// output and with the entries rendered by the passed formatter.
+func NewFormatterLogger(out io.Writer, formatter Formatter) Logger {
+	return &StandardLogger{
+		out:       out,
+		levelOuts: make(map[LogLevel]io.Writer),
+		formatter: formatter,
+	}
 }
 
 // NewTimeformatLogger creates a logger writing to the passed
 // output and with the time formatted with the passed time format.
 func NewTimeformatLogger(out io.Writer, timeFormat string) Logger {
-	return &StandardLogger{
-		out:        out,
-		timeFormat: timeFormat,
-	}
+	return NewFormatterLogger(out, TextFormatter{timeFormat})
 }
 
 // NewStandardLogger creates the standard logger writing
@@ -303,47 +459,128 @@ func NewStandardLogger(out io.Writer) Logger {
 
 // Debug is specified on the Logger interface.
 func (sl *StandardLogger) Debug(info, msg string) {
-	sl.writeLog("DEBUG", info, msg)
+	sl.writeLog(LevelDebug, info, msg)
 }
 
 // Info is specified on the Logger interface.
 func (sl *StandardLogger) Info(info, msg string) {
-	sl.writeLog("INFO", info, msg)
+	sl.writeLog(LevelInfo, info, msg)
 }
 
 // Warning is specified on the Logger interface.
 func (sl *StandardLogger) Warning(info, msg string) {
-	sl.writeLog("WARNING", info, msg)
+	sl.writeLog(LevelWarning, info, msg)
 }
 
 // Error is specified on the Logger interface.
 func (sl *StandardLogger) Error(info, msg string) {
-	sl.writeLog("ERROR", info, msg)
+	sl.writeLog(LevelError, info, msg)
 }
 
 // Critical is specified on the Logger interface.
 func (sl *StandardLogger) Critical(info, msg string) {
-	sl.writeLog("CRITICAL", info, msg)
+	sl.writeLog(LevelCritical, info, msg)
 }
 
 // Fatal is specified on the Logger interface.
 func (sl *StandardLogger) Fatal(info, msg string) {
-	sl.writeLog("FATAL", info, msg)
+	sl.writeLog(LevelFatal, info, msg)
 }
 
-// writeLog writes the concrete log output.
-func (sl *StandardLogger) writeLog(level, info, msg string) {
+// SetFormatter sets the formatter of the entries
+// and returns the current one.
+func (sl *StandardLogger) SetFormatter(formatter Formatter) Formatter {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	current := sl.formatter
+	sl.formatter = formatter
+	return current
+}
+
+// SetWriter sets the writer of the entries
+// and returns the current one.
+func (sl *StandardLogger) SetWriter(out io.Writer) io.Writer {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	current := sl.out
+	sl.out = out
+	return current
+}
+
+// SetLevelWriter sets the writer of the entries with the passed
+// level and above, as long as those have no own writer. It returns
+// the current one. A nil writer removes the routing.
+func (sl *StandardLogger) SetLevelWriter(level LogLevel, out io.Writer) io.Writer {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+	current := sl.levelOuts[level]
+	if out == nil {
+		delete(sl.levelOuts, level)
+	} else {
+		sl.levelOuts[level] = out
+	}
+	return current
+}
+
+// LogEntry is specified on the StructuredLogger interface.
+func (sl *StandardLogger) LogEntry(entry *Entry) {
 	sl.mutex.Lock()
 	defer sl.mutex.Unlock()
 
-	io.WriteString(sl.out, time.Now().Format(sl.timeFormat))
-	io.WriteString(sl.out, " [")
-	io.WriteString(sl.out, level)
-	io.WriteString(sl.out, "] ")
-	io.WriteString(sl.out, info)
-	io.WriteString(sl.out, " ")
-	io.WriteString(sl.out, msg)
-	io.WriteString(sl.out, "\n")
+	out := sl.out
+	for level := entry.Level; level >= LevelDebug; level-- {
+		if lout, ok := sl.levelOuts[level]; ok {
+			out = lout
+			break
+		}
+	}
+	io.WriteString(out, sl.formatter.Format(entry)+"\n")
+}
+
+// writeLog writes the concrete log output.
+func (sl *StandardLogger) writeLog(level LogLevel, info, msg string) {
+	sl.LogEntry(&Entry{
+		Time:  time.Now(),
+		Level: level,
+		Info:  info,
+		Msg:   msg,
+	})
+}
+
+// multiWriter implements io.Writer writing to multiple writers.
+type multiWriter struct {
+	mutex sync.Mutex
+	outs  []io.Writer
+}
+
+// MultiWriter creates a writer duplicating its writes to all passed
+// writers, e.g. to log to stdout and a file. Other than io.MultiWriter
+// it writes to all of them even in case of errors and returns the
+// first one. Writes are serialized, so it can be shared by loggers.
+func MultiWriter(outs ...io.Writer) io.Writer {
+	return &multiWriter{
+		outs: append([]io.Writer{}, outs...),
+	}
+}
+
+// Write implements the io.Writer interface.
+func (mw *multiWriter) Write(p []byte) (int, error) {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+	var first error
+	for _, out := range mw.outs {
+		n, err := out.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return 0, first
+	}
+	return len(p), nil
 }
 
 // GoLogger just uses the standard go log package.
@@ -433,15 +670,62 @@ func retrieveCallInfo() *callInfo {
 	}
 }
 
+// logFields logs a message with its fields. Backends not
+// handling entries get the fields appended to the message.
+func logFields(level LogLevel, info, msg string, fields []Field) {
+	entry := &Entry{
+		Time:   time.Now(),
+		Level:  level,
+		Info:   info,
+		Msg:    msg,
+		Fields: fields,
+	}
+	if sl, ok := logBackend.(StructuredLogger); ok {
+		sl.LogEntry(entry)
+		return
+	}
+	if len(entry.Fields) > 0 {
+		msg += " " + formatFields(entry.Fields)
+	}
+	switch level {
+	case LevelDebug:
+		logBackend.Debug(info, msg)
+	case LevelInfo:
+		logBackend.Info(info, msg)
+	case LevelWarning:
+		logBackend.Warning(info, msg)
+	case LevelError:
+		logBackend.Error(info, msg)
+	case LevelCritical:
+		logBackend.Critical(info, msg)
+	default:
+		logBackend.Fatal(info, msg)
+	}
+}
+
 // shallLog is used inside the logging functions to check if
-// logging is wanted.
+// logging is wanted. Suppressed messages reported by the
+// sampler are logged here.
 func shallLog(level LogLevel, info, msg string) bool {
 	logMutex.RLock()
 	defer logMutex.RUnlock()
-	if logFilter == nil {
+	if logFilter != nil && logFilter(level, info, msg) {
+		return false
+	}
+	if logSampler == nil {
 		return true
 	}
-	return !logFilter(level, info, msg)
+	emit, suppressions := logSampler.Sample(level, info, msg)
+	logSuppressions(suppressions)
+	return emit
+}
+
+// logSuppressions logs the summaries of suppressed messages.
+func logSuppressions(suppressions []Suppression) {
+	for _, s := range suppressions {
+		summary := fmt.Sprintf("suppressed %d messages: %s", s.Count, s.Msg)
+		logFields(s.Level, s.Info, summary, nil)
+	}
 }
 
 // EOF
@@ -12,9 +12,15 @@ package logger_test
 //--------------------
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/tideland/golib/audit"
 	"github.com/tideland/golib/logger"
@@ -176,6 +182,252 @@ func TestOwnLogger(t *testing.T) {
 	assert.Length(ownLogger.logs, 5)
 }
 
+// TestStructured tests logging with structured fields.
+func TestStructured(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	level := logger.Level()
+	defer logger.SetLevel(level)
+
+	logger.SetLevel(logger.LevelDebug)
+
+	// Backend appending the fields.
+	ownLogger := &testLogger{}
+	logger.SetLogger(ownLogger)
+
+	logger.Infow("Info.", "cell", "foo", "topic", "bar baz")
+	logger.Errorw("Error.", "count", 42)
+	logger.Warningw("Warning.", "lonely")
+	logger.Debugw("Debug.")
+	assert.Length(ownLogger.logs, 4)
+	assert.Substring(`Info. cell=foo topic="bar baz"`, ownLogger.logs[0])
+	assert.Substring("Error. count=42", ownLogger.logs[1])
+	assert.Substring("Warning. !BADKEY=lonely", ownLogger.logs[2])
+	assert.True(strings.HasSuffix(ownLogger.logs[3], "Debug."))
+
+	// Standard logger with text formatter.
+	buf := &bytes.Buffer{}
+	logger.SetLogger(logger.NewFormatterLogger(buf, logger.TextFormatter{TimeFormat: "15:04"}))
+
+	logger.Criticalw("Critical.", "cell", "foo", 4711, true)
+	logger.Infof("Info.")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Length(lines, 2)
+	assert.Match(lines[0], `^[0-9]{2}:[0-9]{2} \[CRITICAL\] .* Critical\. cell=foo 4711=true$`)
+	assert.Match(lines[1], `^[0-9]{2}:[0-9]{2} \[INFO\] \[.*\] Info\.$`)
+
+	assert.Equal(logger.Fields("a", 1, "b"), []logger.Field{{Key: "a", Value: 1}, {Key: "!BADKEY", Value: "b"}})
+}
+
+// TestJSONFormatter tests logging with the JSON formatter.
+func TestJSONFormatter(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	level := logger.Level()
+	defer logger.SetLevel(level)
+
+	logger.SetLevel(logger.LevelDebug)
+	buf := &bytes.Buffer{}
+	logger.SetLogger(logger.NewStandardLogger(buf))
+	current := logger.SetFormatter(logger.JSONFormatter{})
+	assert.Equal(current, logger.TextFormatter{TimeFormat: "2006-01-02 15:04:05 Z07:00"})
+
+	msg := "Say \"hello\",\n\tworld! \\ <ü>"
+	logger.Infow(msg, "cell", "foo", "message", "bar", "err", errors.New("ouch"))
+	logger.Errorf("Error.")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Length(lines, 2)
+	entry := map[string]interface{}{}
+	err := json.Unmarshal([]byte(lines[0]), &entry)
+	assert.Nil(err)
+	assert.Equal(entry["level"], "INFO")
+	assert.Equal(entry["message"], msg)
+	assert.Equal(entry["cell"], "foo")
+	assert.Equal(entry["field.message"], "bar")
+	assert.Equal(entry["err"], "ouch")
+	_, err = time.Parse(time.RFC3339, entry["time"].(string))
+	assert.Nil(err)
+	entry = map[string]interface{}{}
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	assert.Nil(err)
+	assert.Equal(entry["message"], "Error.")
+
+	buf.Reset()
+	logger.SetFormatter(logger.JSONFormatter{TimeFormat: "2006"})
+	logger.Infof("Info.")
+	assert.Match(buf.String(), `^\{"time":"[0-9]{4}","level":"INFO",.*\}\n$`)
+
+	logger.SetLogger(&testLogger{})
+	assert.Nil(logger.SetFormatter(logger.JSONFormatter{}))
+}
+
+// TestWith tests logging with bound fields.
+func TestWith(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	level := logger.Level()
+	defer logger.SetLevel(level)
+
+	logger.SetLevel(logger.LevelDebug)
+	buf := &bytes.Buffer{}
+	logger.SetLogger(logger.NewStandardLogger(buf))
+
+	parent := logger.With("cell", "foo")
+	child := parent.With("trace", 4711)
+
+	child.Infow("Child.", "topic", "bar")
+	child.Errorf("Child %d.", 2)
+	parent.Infow("Parent.", "topic", "baz")
+	logger.Infow("Package.")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Length(lines, 4)
+	assert.Match(lines[0], `.*/logger_test\] Child\. cell=foo trace=4711 topic=bar$`)
+	assert.Match(lines[1], `.*\[ERROR\] .* Child 2\. cell=foo trace=4711$`)
+	assert.Match(lines[2], `.* Parent\. cell=foo topic=baz$`)
+	assert.Match(lines[3], `.* Package\.$`)
+
+	// Concurrent usage of parent and children.
+	out := &testWriter{}
+	logger.SetLogger(logger.NewFormatterLogger(out, logger.TextFormatter{}))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := parent.With("child", i)
+			for j := 0; j < 10; j++ {
+				c.Debugw("Debug.", "j", j)
+				parent.Debugw("Debug.", "i", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	logs := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Length(logs, 200)
+	for _, log := range logs {
+		assert.Match(log, `.* Debug\. cell=foo (child=[0-9] j|i)=[0-9]$`)
+	}
+}
+
+// TestWriters tests the setting of writers.
+func TestWriters(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	level := logger.Level()
+	defer logger.SetLevel(level)
+
+	logger.SetLevel(logger.LevelDebug)
+	logger.SetLogger(logger.NewStandardLogger(os.Stdout))
+	bufA := &testWriter{}
+	bufB := &testWriter{}
+	bufErr := &testWriter{}
+
+	current := logger.SetWriter(logger.MultiWriter(bufA, bufB))
+	assert.Equal(current, os.Stdout)
+	current = logger.SetLevelWriter(logger.LevelError, bufErr)
+	assert.Nil(current)
+
+	logger.Infof("Info.")
+	logger.Warningf("Warning.")
+	logger.Errorf("Error.")
+	logger.Criticalf("Critical.")
+	assert.Equal(bufA.String(), bufB.String())
+	assert.Length(strings.Split(strings.TrimSpace(bufA.String()), "\n"), 2)
+	assert.Substring("Warning.", bufA.String())
+	assert.Length(strings.Split(strings.TrimSpace(bufErr.String()), "\n"), 2)
+	assert.Substring("Critical.", bufErr.String())
+
+	current = logger.SetLevelWriter(logger.LevelError, nil)
+	assert.Equal(current, bufErr)
+	logger.Errorf("Error.")
+	assert.Length(strings.Split(strings.TrimSpace(bufA.String()), "\n"), 3)
+
+	// Concurrent logging into one writer.
+	out := &testWriter{}
+	logger.SetWriter(logger.MultiWriter(out))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.Infow("Concurrent.", "i", i, "j", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	logs := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Length(logs, 1000)
+	for _, log := range logs {
+		assert.Match(log, `.* \[INFO\] .* Concurrent\. i=[0-9]+ j=[0-9]+$`)
+	}
+
+	logger.SetLogger(&testLogger{})
+	assert.Nil(logger.SetWriter(os.Stdout))
+}
+
+// TestSampler tests the suppression of identical messages.
+func TestSampler(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	level := logger.Level()
+	defer logger.SetLevel(level)
+	defer logger.SetSampler(nil)
+
+	logger.SetLevel(logger.LevelDebug)
+	ownLogger := &testLogger{}
+	logger.SetLogger(ownLogger)
+	logger.SetSampler(logger.NewRateSampler(5, 100*time.Millisecond))
+
+	for i := 0; i < 100; i++ {
+		logger.Warningf("Flooding.")
+		logger.Errorw("Flooding.", "i", i)
+	}
+	logger.Infof("Other.")
+	assert.Length(ownLogger.Logs(), 11)
+
+	time.Sleep(150 * time.Millisecond)
+
+	// The suppressions are reported after the interval, the
+	// messages are logged again.
+	assert.Length(ownLogger.Logs(), 13)
+	assert.Substring("[WARNING]", ownLogger.Logs()[11])
+	assert.Substring("suppressed 95 messages: Flooding.", ownLogger.Logs()[11])
+	assert.Substring("[ERROR]", ownLogger.Logs()[12])
+	assert.Substring("suppressed 95 messages: Flooding.", ownLogger.Logs()[12])
+	logger.Warningf("Flooding.")
+	assert.Length(ownLogger.Logs(), 14)
+	assert.True(strings.HasSuffix(ownLogger.Logs()[13], " Flooding."))
+	logger.Infof("Other.")
+	assert.Length(ownLogger.Logs(), 15)
+	logger.Errorw("Flooding.")
+	assert.Length(ownLogger.Logs(), 16)
+
+	// A stopped flood is reported without any later message.
+	for i := 0; i < 10; i++ {
+		logger.Errorf("Stopped.")
+	}
+	assert.Length(ownLogger.Logs(), 21)
+
+	time.Sleep(150 * time.Millisecond)
+
+	assert.Length(ownLogger.Logs(), 22)
+	assert.Substring("[ERROR]", ownLogger.Logs()[21])
+	assert.Substring("suppressed 5 messages: Stopped.", ownLogger.Logs()[21])
+
+	// Replacing the sampler reports the pending suppressions.
+	for i := 0; i < 10; i++ {
+		logger.Warningf("Replaced.")
+	}
+	assert.Length(ownLogger.Logs(), 27)
+	logger.SetSampler(nil)
+	assert.Length(ownLogger.Logs(), 28)
+	assert.Substring("[WARNING]", ownLogger.Logs()[27])
+	assert.Substring("suppressed 5 messages: Replaced.", ownLogger.Logs()[27])
+
+	for i := 0; i < 10; i++ {
+		logger.Warningf("Flooding.")
+	}
+	assert.Length(ownLogger.Logs(), 38)
+}
+
 // TestFatalExit tests the call of the fatal exiter after a
 // fatal error log.
 func TestFatalExit(t *testing.T) {
@@ -202,31 +454,65 @@ func TestFatalExit(t *testing.T) {
 //--------------------
 
 type testLogger struct {
-	logs []string
+	mutex sync.Mutex
+	logs  []string
+}
+
+func (tl *testLogger) add(entry string) {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	tl.logs = append(tl.logs, entry)
+}
+
+func (tl *testLogger) Logs() []string {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	return append([]string{}, tl.logs...)
 }
 
 func (tl *testLogger) Debug(info, msg string) {
-	tl.logs = append(tl.logs, "[DEBUG] "+info+" "+msg)
+	tl.add("[DEBUG] " + info + " " + msg)
 }
 
 func (tl *testLogger) Info(info, msg string) {
-	tl.logs = append(tl.logs, "[INFO] "+info+" "+msg)
+	tl.add("[INFO] " + info + " " + msg)
 }
 
 func (tl *testLogger) Warning(info, msg string) {
-	tl.logs = append(tl.logs, "[WARNING] "+info+" "+msg)
+	tl.add("[WARNING] " + info + " " + msg)
 }
 
 func (tl *testLogger) Error(info, msg string) {
-	tl.logs = append(tl.logs, "[ERROR] "+info+" "+msg)
+	tl.add("[ERROR] " + info + " " + msg)
 }
 
 func (tl *testLogger) Critical(info, msg string) {
-	tl.logs = append(tl.logs, "[CRITICAL] "+info+" "+msg)
+	tl.add("[CRITICAL] " + info + " " + msg)
 }
 
 func (tl *testLogger) Fatal(info, msg string) {
-	tl.logs = append(tl.logs, "[FATAL] "+info+" "+msg)
+	tl.add("[FATAL] " + info + " " + msg)
+}
+
+//--------------------
+// WRITER
+//--------------------
+
+type testWriter struct {
+	mutex sync.Mutex
+	out   bytes.Buffer
+}
+
+func (tw *testWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	return tw.out.Write(p)
+}
+
+func (tw *testWriter) String() string {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	return tw.out.String()
 }
 
 // EOF
@@ -0,0 +1,210 @@
+// Tideland Go Library - Logger - Sampler
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package logger
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//--------------------
+// SAMPLER
+//--------------------
+
+// Suppression tells how many identical messages have been
+// suppressed by a sampler.
+type Suppression struct {
+	Level LogLevel
+	Info  string
+	Msg   string
+	Count int
+}
+
+// Sampler decides if a message is logged to reduce the output of
+// identical messages. It's called for all messages passing the
+// level and the filter except fatal ones. Beside the decision it
+// returns the suppressions to report, those may belong to other
+// messages too. Only the message text is passed, the fields of
+// structured messages are not part of the sampling.
+type Sampler interface {
+	Sample(level LogLevel, info, msg string) (bool, []Suppression)
+
+	// Flush returns the suppressions not reported so far and
+	// forgets them. It's called when the sampler is replaced.
+	Flush() []Suppression
+}
+
+// sample contains the state of one message inside the
+// current interval.
+type sample struct {
+	level      LogLevel
+	info       string
+	msg        string
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// suppression returns the suppression of the sample.
+func (s *sample) suppression() Suppression {
+	return Suppression{
+		Level: s.level,
+		Info:  s.info,
+		Msg:   s.msg,
+		Count: s.suppressed,
+	}
+}
+
+// rateSampler implements the Sampler interface.
+type rateSampler struct {
+	mutex     sync.Mutex
+	max       int
+	interval  time.Duration
+	lastPrune time.Time
+	samples   map[string]*sample
+	timer     *time.Timer
+}
+
+// NewRateSampler creates a sampler logging at most max identical
+// messages per interval. Messages are identical if level, info, and
+// message text are equal, fields of structured messages are not
+// compared. The number of suppressed ones is logged when the interval
+// is over, even if no further message follows.
+func NewRateSampler(max int, interval time.Duration) Sampler {
+	return &rateSampler{
+		max:       max,
+		interval:  interval,
+		lastPrune: time.Now(),
+		samples:   make(map[string]*sample),
+	}
+}
+
+// Sample implements the Sampler interface.
+func (rs *rateSampler) Sample(level LogLevel, info, msg string) (bool, []Suppression) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	now := time.Now()
+	key := level.String() + " " + info + " " + msg
+	var suppressions []Suppression
+	if s, ok := rs.samples[key]; ok && now.Sub(s.start) >= rs.interval {
+		if s.suppressed > 0 {
+			suppressions = append(suppressions, s.suppression())
+		}
+		delete(rs.samples, key)
+	}
+	suppressions = append(suppressions, rs.prune(now)...)
+	s, ok := rs.samples[key]
+	if !ok {
+		rs.samples[key] = &sample{
+			level: level,
+			info:  info,
+			msg:   msg,
+			start: now,
+			count: 1,
+		}
+		return true, suppressions
+	}
+	s.count++
+	if s.count <= rs.max {
+		return true, suppressions
+	}
+	s.suppressed++
+	if rs.timer == nil {
+		rs.timer = time.AfterFunc(s.start.Add(rs.interval).Sub(now), rs.report)
+	}
+	return false, suppressions
+}
+
+// Flush implements the Sampler interface.
+func (rs *rateSampler) Flush() []Suppression {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if rs.timer != nil {
+		rs.timer.Stop()
+		rs.timer = nil
+	}
+	var suppressions []Suppression
+	for _, s := range rs.samples {
+		if s.suppressed > 0 {
+			suppressions = append(suppressions, s.suppression())
+		}
+	}
+	rs.samples = make(map[string]*sample)
+	sortSuppressions(suppressions)
+	return suppressions
+}
+
+// report is called by the timer after the interval of a sample
+// with suppressions. It logs them if the sampler is still the
+// active one and waits for the next sample with suppressions.
+func (rs *rateSampler) report() {
+	logMutex.RLock()
+	defer logMutex.RUnlock()
+	if logSampler != Sampler(rs) {
+		return
+	}
+	rs.mutex.Lock()
+	now := time.Now()
+	rs.timer = nil
+	suppressions := rs.expire(now)
+	var next *sample
+	for _, s := range rs.samples {
+		if s.suppressed > 0 && (next == nil || s.start.Before(next.start)) {
+			next = s
+		}
+	}
+	if next != nil {
+		rs.timer = time.AfterFunc(next.start.Add(rs.interval).Sub(now), rs.report)
+	}
+	rs.mutex.Unlock()
+	logSuppressions(suppressions)
+}
+
+// prune removes the samples of messages of which the interval
+// is over and returns the suppressions still to report. It's
+// done at most once per interval.
+func (rs *rateSampler) prune(now time.Time) []Suppression {
+	if now.Sub(rs.lastPrune) < rs.interval {
+		return nil
+	}
+	rs.lastPrune = now
+	return rs.expire(now)
+}
+
+// expire removes the samples of messages of which the interval
+// is over and returns their suppressions.
+func (rs *rateSampler) expire(now time.Time) []Suppression {
+	var suppressions []Suppression
+	for key, s := range rs.samples {
+		if now.Sub(s.start) >= rs.interval {
+			if s.suppressed > 0 {
+				suppressions = append(suppressions, s.suppression())
+			}
+			delete(rs.samples, key)
+		}
+	}
+	sortSuppressions(suppressions)
+	return suppressions
+}
+
+// sortSuppressions sorts suppressions by level, info, and message.
+func sortSuppressions(suppressions []Suppression) {
+	sort.Slice(suppressions, func(i, j int) bool {
+		if suppressions[i].Level != suppressions[j].Level {
+			return suppressions[i].Level < suppressions[j].Level
+		}
+		return suppressions[i].Info+suppressions[i].Msg < suppressions[j].Info+suppressions[j].Msg
+	})
+}
+
+// EOF
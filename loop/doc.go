@@ -17,7 +17,15 @@
 // recoverable. In this case a user defined recovery function
 // gets notified if a loop ends with an error or panics.
 // The paseed passed list of recovering information helps
-// to check the reason and frequency.
+// to check the reason and frequency. The number of recoverings,
+// the last error and the uptime of a loop can be retrieved while
+// it is running. Loops started with GoRestartable() are restarted
+// after errors too, following a RestartPolicy with a limit of
+// restarts inside a time window and a backoff between them.
+//
+// Loops started with GoContext() get a context instead of the loop.
+// It is cancelled when the loop is stopped or killed, and cancelling
+// the passed context stops the loop.
 //
 // A third way are sentinels. Those can monitor multiple
 // loops and other sentinels. So hierarchies can be defined.
@@ -27,6 +35,13 @@
 // the handler function and optionally restart the loop
 // or sentinel.
 //
+// SetGracePeriod() enables a watchdog for stopping loops. If a loop
+// function does not return within the period a dump of all goroutines
+// is logged and Stop() returns an error.
+//
+// Groups bundle loops to stop or wait for them together. The
+// errors of the single loops are returned as collection.
+//
 // See the example functions for more information.
 package loop
 
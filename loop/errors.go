@@ -24,6 +24,10 @@ const (
 	ErrHandlingFailed
 	ErrRestartNonStopped
 	ErrKilledBySentinel
+	ErrGroupMemberFailed
+	ErrGroupTimeout
+	ErrRestartLimit
+	ErrStopTimeout
 )
 
 var errorMessages = errors.Messages{
@@ -31,6 +35,10 @@ var errorMessages = errors.Messages{
 	ErrHandlingFailed:    "error handling for %q failed",
 	ErrRestartNonStopped: "cannot restart unstopped %q",
 	ErrKilledBySentinel:  "%q killed by sentinel",
+	ErrGroupMemberFailed: "loop %q of group failed",
+	ErrGroupTimeout:      "loop %q of group did not stop in time",
+	ErrRestartLimit:      "loop exceeded limit of %d restarts",
+	ErrStopTimeout:       "loop %q did not stop within %v",
 }
 
 //--------------------
@@ -44,4 +52,10 @@ func IsKilledBySentinelError(err error) bool {
 	return errors.IsError(err, ErrKilledBySentinel)
 }
 
+// IsStopTimeoutError allows to check, if a loop function
+// did not return within the grace period after stopping.
+func IsStopTimeoutError(err error) bool {
+	return errors.IsError(err, ErrStopTimeout)
+}
+
 // EOF
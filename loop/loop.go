@@ -12,8 +12,11 @@ package loop
 //--------------------
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tideland/golib/errors"
@@ -50,6 +53,34 @@ func GoRecoverable(lf LoopFunc, rf RecoverFunc, dps ...interface{}) Loop {
 	return goLoop(lf, rf, nil, nil, descr)
 }
 
+// GoRestartable starts the loop function in the background. The
+// loop can be stopped or killed like with Go. If the loop function
+// returns an error or panics it is restarted following the passed
+// policy. If its limit is exceeded the loop ends with the last error.
+func GoRestartable(lf LoopFunc, policy RestartPolicy, dps ...interface{}) Loop {
+	descr := identifier.SepIdentifier("::", dps...)
+	rlf, rf := policy.funcs(lf)
+	return goLoop(rlf, rf, nil, nil, descr)
+}
+
+// GoContext starts the context function in the background. The
+// function gets a context derived from the passed one. It is cancelled
+// when the loop is stopped or killed. If the passed context is
+// cancelled the loop stops as well. The function then has to end
+// working returning a possible error.
+func GoContext(ctx context.Context, fn func(ctx context.Context) error, dps ...interface{}) Loop {
+	descr := identifier.SepIdentifier("::", dps...)
+	return goLoop(contextLoopFunc(ctx, fn), nil, nil, nil, descr)
+}
+
+// SetGracePeriod sets the period a loop function has to return
+// after stopping the loop. Otherwise a dump of all goroutines is
+// logged and Stop returns an error. A period not greater than zero,
+// which is the default, disables the check.
+func SetGracePeriod(period time.Duration) {
+	atomic.StoreInt64(&gracePeriod, int64(period))
+}
+
 // GoSentinel starts a new sentinel. It can manage loops and other sentinels
 // and will stop them in case of errors.
 func GoSentinel(dps ...interface{}) Sentinel {
@@ -65,6 +96,9 @@ func GoNotifiedSentinel(nhf NotificationHandlerFunc, dps ...interface{}) Sentine
 	return goSentinel(nhf, nil, descr)
 }
 
+// gracePeriod is the period set with SetGracePeriod.
+var gracePeriod int64
+
 //--------------------
 // RECOVERING
 //--------------------
@@ -104,6 +138,17 @@ func (rs Recoverings) Trim(l int) Recoverings {
 	return rs[len(rs)-l:]
 }
 
+// within returns the number of the last recoverings happened
+// during the given duration before the last one.
+func (rs Recoverings) within(dur time.Duration) int {
+	last := rs.Last()
+	n := 0
+	for i := len(rs) - 1; i >= 0 && last.Time.Sub(rs[i].Time) <= dur; i-- {
+		n++
+	}
+	return n
+}
+
 // Last returns the last recovering.
 func (rs Recoverings) First() *Recovering {
 	if len(rs) > 0 {
@@ -125,6 +170,64 @@ func (rs Recoverings) Last() *Recovering {
 // list of revocerings if needed.
 type RecoverFunc func(rs Recoverings) (Recoverings, error)
 
+//--------------------
+// RESTART POLICY
+//--------------------
+
+// RestartPolicy controls the restarting of loops started with
+// GoRestartable. MaxRestarts is the number of restarts allowed
+// inside the duration Window, if it isn't positive the number
+// of all restarts is limited. Backoff returns the duration to
+// wait before restart n inside the window, starting with 1.
+// Without it the loop is restarted immediately.
+type RestartPolicy struct {
+	MaxRestarts int
+	Window      time.Duration
+	Backoff     func(n int) time.Duration
+}
+
+// funcs returns the loop function waiting for the backoff and
+// the recover function checking the limit.
+func (rp RestartPolicy) funcs(lf LoopFunc) (LoopFunc, RecoverFunc) {
+	// Both are called sequentially by the loop goroutine.
+	var wait time.Duration
+	rlf := func(l Loop) error {
+		if wait > 0 {
+			d := wait
+			wait = 0
+			select {
+			case <-l.ShallStop():
+				return nil
+			case <-time.After(d):
+			}
+		}
+		return lf(l)
+	}
+	rf := func(rs Recoverings) (Recoverings, error) {
+		limit := rs.Len() > rp.MaxRestarts
+		n := rs.Len()
+		if rp.Window > 0 {
+			limit = rs.Frequency(rp.MaxRestarts+1, rp.Window)
+			rs = rs.Trim(rp.MaxRestarts + 1)
+			n = rs.within(rp.Window)
+		}
+		if limit {
+			reason := rs.Last().Reason
+			err, ok := reason.(error)
+			if !ok {
+				err = errors.New(ErrLoopPanicked, errorMessages, reason)
+			}
+			return rs, errors.Annotate(err, ErrRestartLimit, errorMessages, rp.MaxRestarts)
+		}
+		wait = 0
+		if rp.Backoff != nil {
+			wait = rp.Backoff(n)
+		}
+		return rs, nil
+	}
+	return rlf, rf
+}
+
 //--------------------
 // OBSERVABLE
 //--------------------
@@ -180,6 +283,19 @@ type Loop interface {
 	// the loop is stopping or to avoid deadlocks when communicating
 	// with the loop.
 	IsStopping() <-chan struct{}
+
+	// Recoverings returns the number of recoverings since the
+	// loop has been started or restarted.
+	Recoverings() int
+
+	// LastError returns the last error or panic of the loop
+	// function, also if the loop recovered from it.
+	LastError() error
+
+	// Uptime returns the duration the loop is running since its
+	// start or restart. After stopping it is the duration of the
+	// last run.
+	Uptime() time.Duration
 }
 
 // Loop manages a loop function.
@@ -191,6 +307,10 @@ type loop struct {
 	loopF       LoopFunc
 	recoverF    RecoverFunc
 	recoverings Recoverings
+	recovered   int
+	lastErr     error
+	startTime   time.Time
+	stopTime    time.Time
 	startedC    chan struct{}
 	stopC       chan struct{}
 	doneC       chan struct{}
@@ -201,14 +321,15 @@ type loop struct {
 // goLoop starts a loop in the background.
 func goLoop(lf LoopFunc, rf RecoverFunc, o Observable, s *sentinel, d string) *loop {
 	l := &loop{
-		descr:    d,
-		loopF:    lf,
-		recoverF: rf,
-		startedC: make(chan struct{}),
-		stopC:    make(chan struct{}),
-		doneC:    make(chan struct{}),
-		owner:    o,
-		sentinel: s,
+		descr:     d,
+		loopF:     lf,
+		recoverF:  rf,
+		startedC:  make(chan struct{}),
+		stopC:     make(chan struct{}),
+		doneC:     make(chan struct{}),
+		owner:     o,
+		sentinel:  s,
+		startTime: time.Now(),
 	}
 	// Check description.
 	if l.descr == "" {
@@ -234,6 +355,17 @@ func (l *loop) String() string {
 // Stop implements the Observable interface.
 func (l *loop) Stop() error {
 	l.terminate(nil)
+	if period := time.Duration(atomic.LoadInt64(&gracePeriod)); period > 0 {
+		l.mux.Lock()
+		doneC := l.doneC
+		l.mux.Unlock()
+		select {
+		case <-doneC:
+		case <-time.After(period):
+			logger.Errorf("loop %q did not stop within %v, goroutines:\n%s", l, period, goroutineDump())
+			return errors.New(ErrStopTimeout, errorMessages, l, period)
+		}
+	}
 	return l.Wait()
 }
 
@@ -260,6 +392,10 @@ func (l *loop) Restart() error {
 	}
 	l.err = nil
 	l.recoverings = nil
+	l.recovered = 0
+	l.lastErr = nil
+	l.startTime = time.Now()
+	l.stopTime = time.Time{}
 	l.status = Running
 	l.stopC = make(chan struct{})
 	l.doneC = make(chan struct{})
@@ -299,6 +435,30 @@ func (l *loop) IsStopping() <-chan struct{} {
 	return l.stopC
 }
 
+// Recoverings implements the Loop interface.
+func (l *loop) Recoverings() int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.recovered
+}
+
+// LastError implements the Loop interface.
+func (l *loop) LastError() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.lastErr
+}
+
+// Uptime implements the Loop interface.
+func (l *loop) Uptime() time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.stopTime.IsZero() {
+		return time.Since(l.startTime)
+	}
+	return l.stopTime.Sub(l.startTime)
+}
+
 // run operates the loop as goroutine.
 func (l *loop) run() {
 	l.status = Running
@@ -321,6 +481,29 @@ func (l *loop) run() {
 	}
 }
 
+// contextLoopFunc wraps a context function into a loop function.
+func contextLoopFunc(ctx context.Context, fn func(ctx context.Context) error) LoopFunc {
+	return func(l Loop) error {
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		doneC := make(chan struct{})
+		defer close(doneC)
+		go func() {
+			select {
+			case <-l.ShallStop():
+				cancel()
+			case <-ctx.Done():
+				// Stop the loop without error, the function
+				// decides about the result.
+				cancel()
+				l.Kill(nil)
+			case <-doneC:
+			}
+		}()
+		return fn(lctx)
+	}
+}
+
 // terminate tells the loop to stop working and stores
 // the passed error if none has been stored already.
 func (l *loop) terminate(err error) {
@@ -329,6 +512,9 @@ func (l *loop) terminate(err error) {
 	if l.err == nil {
 		l.err = err
 	}
+	if err != nil {
+		l.lastErr = err
+	}
 	if l.status != Running {
 		return
 	}
@@ -343,6 +529,16 @@ func (l *loop) terminate(err error) {
 // checkTermination checks if an error has been the reason and if
 // it possibly can be recovered by a recover function.
 func (l *loop) checkTermination(reason interface{}) {
+	var err error
+	if reason != nil {
+		var ok bool
+		if err, ok = reason.(error); !ok {
+			err = errors.New(ErrLoopPanicked, errorMessages, reason)
+		}
+		l.mux.Lock()
+		l.lastErr = err
+		l.mux.Unlock()
+	}
 	switch {
 	case reason == nil:
 		// Regular end.
@@ -353,14 +549,13 @@ func (l *loop) checkTermination(reason interface{}) {
 		if l.err != nil {
 			break
 		}
-		if err, ok := reason.(error); ok {
-			l.err = err
-		} else {
-			l.err = errors.New(ErrLoopPanicked, errorMessages, reason)
-		}
+		l.err = err
 	default:
 		// Try to recover.
 		logger.Errorf("loop %q tries to recover", l)
+		l.mux.Lock()
+		l.recovered++
+		l.mux.Unlock()
 		l.recoverings = append(l.recoverings, &Recovering{time.Now(), reason})
 		l.recoverings, l.err = l.recoverF(l.recoverings)
 		if l.err != nil {
@@ -375,6 +570,9 @@ func (l *loop) checkTermination(reason interface{}) {
 // working and a potential sentinal about its status.
 func (l *loop) finalizeTermination() {
 	l.status = Stopped
+	l.mux.Lock()
+	l.stopTime = time.Now()
+	l.mux.Unlock()
 	// Close stopC in case  the termination is due to an
 	// error or internal.
 	select {
@@ -405,6 +603,18 @@ func (l *loop) finalizeTermination() {
 	}
 }
 
+// goroutineDump returns the stack traces of all goroutines.
+func goroutineDump() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
 //--------------------
 // SENTINEL
 //--------------------
@@ -590,4 +800,112 @@ func (s *sentinel) backendLoop(l Loop) error {
 	}
 }
 
+//--------------------
+// GROUP
+//--------------------
+
+// Group bundles multiple loops to stop and wait for them together.
+type Group interface {
+	// Add adds the passed loops to the group.
+	Add(ls ...Loop)
+
+	// Stop stops all loops of the group concurrently and waits
+	// until they ended or the timeout of the group is reached.
+	// The errors of the loops are returned as collection.
+	Stop() error
+
+	// Wait blocks the caller until all loops of the group ended
+	// and returns their errors as collection.
+	Wait() error
+}
+
+// group implements the Group interface.
+type group struct {
+	mux     sync.Mutex
+	timeout time.Duration
+	loops   []Loop
+}
+
+// NewGroup creates a new group of loops. The timeout limits
+// the total duration of stopping the loops, it is not limited
+// if the timeout is not positive.
+func NewGroup(timeout time.Duration) Group {
+	return &group{
+		timeout: timeout,
+	}
+}
+
+// Add implements the Group interface.
+func (g *group) Add(ls ...Loop) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.loops = append(g.loops, ls...)
+}
+
+// Stop implements the Group interface.
+func (g *group) Stop() error {
+	return g.collect(func(l Loop) error {
+		return l.Stop()
+	}, g.timeout)
+}
+
+// Wait implements the Group interface.
+func (g *group) Wait() error {
+	return g.collect(func(l Loop) error {
+		return l.Wait()
+	}, 0)
+}
+
+// collect concurrently executes the passed function for all loops
+// and collects their errors in the order of the loops. Loops not
+// done before a positive timeout are reported with an error.
+func (g *group) collect(f func(l Loop) error, timeout time.Duration) error {
+	g.mux.Lock()
+	ls := make([]Loop, len(g.loops))
+	copy(ls, g.loops)
+	g.mux.Unlock()
+	// Run the function in the background.
+	type result struct {
+		index int
+		err   error
+	}
+	resultC := make(chan result, len(ls))
+	for i, l := range ls {
+		go func(i int, l Loop) {
+			resultC <- result{i, f(l)}
+		}(i, l)
+	}
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	// Wait for the results.
+	errs := make([]error, len(ls))
+	done := make([]bool, len(ls))
+collecting:
+	for n := 0; n < len(ls); n++ {
+		select {
+		case r := <-resultC:
+			done[r.index] = true
+			if r.err != nil {
+				errs[r.index] = errors.Annotate(r.err, ErrGroupMemberFailed, errorMessages, ls[r.index])
+			}
+		case <-timeoutC:
+			for i, l := range ls {
+				if !done[i] {
+					errs[i] = errors.New(ErrGroupTimeout, errorMessages, l)
+				}
+			}
+			break collecting
+		}
+	}
+	ec := &errors.Collection{}
+	for _, err := range errs {
+		ec.Append(err)
+	}
+	return ec.ToError()
+}
+
 // EOF
@@ -12,11 +12,14 @@ package loop_test
 //--------------------
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/tideland/golib/audit"
+	golerrors "github.com/tideland/golib/errors"
 	"github.com/tideland/golib/loop"
 )
 
@@ -92,6 +95,26 @@ func TestSimpleKill(t *testing.T) {
 	assert.Equal(loop.Stopped, status, "loop is stopped")
 }
 
+// TestStopGracePeriod tests the grace period for stopping loops.
+func TestStopGracePeriod(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	loop.SetGracePeriod(shortTimeout)
+	defer loop.SetGracePeriod(0)
+
+	l := loop.Go(makeSimpleLF(donec), "grace-period")
+
+	assert.Nil(l.Stop())
+	assert.Wait(donec, true, shortTimeout)
+
+	l = loop.Go(makeStubbornLF(longTimeout), "grace-period")
+	err := l.Stop()
+
+	assert.ErrorMatch(err, `.*loop "grace-period" did not stop within 25ms`)
+	assert.True(loop.IsStopTimeoutError(err))
+	assert.Nil(l.Wait())
+}
+
 // TestError tests an internal error.
 func TestError(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -121,6 +144,46 @@ func TestDeferredError(t *testing.T) {
 	assert.Equal(loop.Stopped, status, "loop is stopped")
 }
 
+// TestContextStop tests stopping a context loop.
+func TestContextStop(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	l := loop.GoContext(context.Background(), makeContextF(donec), "context-stop")
+
+	assert.Nil(l.Stop())
+	assert.Wait(donec, context.Canceled, shortTimeout)
+
+	status, _ := l.Error()
+
+	assert.Equal(loop.Stopped, status, "loop is stopped")
+}
+
+// TestContextCancel tests cancelling the context of a loop.
+func TestContextCancel(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	ctx, cancel := context.WithCancel(context.Background())
+	l := loop.GoContext(ctx, makeContextF(donec), "context-cancel")
+
+	cancel()
+	assert.Wait(donec, context.Canceled, shortTimeout)
+	assert.Nil(l.Wait())
+
+	status, _ := l.Error()
+
+	assert.Equal(loop.Stopped, status, "loop is stopped")
+
+	// Function returning the context error.
+	ctx, cancel = context.WithTimeout(context.Background(), shortTimeout)
+	defer cancel()
+	l = loop.GoContext(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, "context-timeout")
+
+	assert.Equal(l.Wait(), context.DeadlineExceeded)
+}
+
 // TestStopRecoverings tests the regular stop of a recovered loop.
 func TestStopRecoverings(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -167,6 +230,35 @@ func TestRecoveringsPanic(t *testing.T) {
 	assert.Equal(loop.Stopped, status)
 }
 
+// TestRecoveringsMetrics tests the accessors for recoverings,
+// last error and uptime.
+func TestRecoveringsMetrics(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	l := loop.GoRecoverable(makeRecoverPanicLF(), makeIgnorePanicsRF(donec), "recoverings-metrics")
+
+	assert.Equal(l.Recoverings(), 0)
+	assert.Nil(l.LastError())
+
+	assert.Wait(donec, "recovered", longTimeout)
+	assert.Equal(l.Recoverings(), 1)
+	assert.Wait(donec, "recovered", longTimeout)
+	assert.Equal(l.Recoverings(), 2)
+	assert.ErrorMatch(l.LastError(), ".*loop panicked: ouch")
+
+	assert.Nil(l.Stop())
+	uptime := l.Uptime()
+	assert.True(uptime >= 2*shortTimeout)
+	time.Sleep(shortTimeout)
+	assert.Equal(l.Uptime(), uptime, "uptime stays after stop")
+
+	assert.Nil(l.Restart())
+	assert.Equal(l.Recoverings(), 0)
+	assert.Nil(l.LastError())
+	assert.True(l.Uptime() < shortTimeout)
+	assert.Nil(l.Stop())
+}
+
 // TestRecoveringsError tests recoverings after errors
 func TestRecoveringsError(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -183,6 +275,53 @@ func TestRecoveringsError(t *testing.T) {
 	assert.Equal(loop.Stopped, status, "loop is stopped")
 }
 
+// TestRestartable tests restarting a loop after errors.
+func TestRestartable(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	var backoffs []int
+	policy := loop.RestartPolicy{
+		MaxRestarts: 3,
+		Window:      time.Second,
+		Backoff: func(n int) time.Duration {
+			backoffs = append(backoffs, n)
+			return time.Millisecond
+		},
+	}
+	l := loop.GoRestartable(makeStabilizingLF(2, donec), policy, "restartable")
+
+	assert.Wait(donec, "stable", longTimeout)
+	assert.Equal(l.Recoverings(), 2)
+	assert.Equal(backoffs, []int{1, 2})
+	assert.ErrorMatch(l.LastError(), "failure 2")
+	assert.Nil(l.Stop())
+
+	// Exceeding the limit.
+	l = loop.GoRestartable(makeStabilizingLF(5, donec), policy, "restartable")
+
+	assert.ErrorMatch(l.Wait(), `.*loop exceeded limit of 3 restarts: failure 4`)
+	assert.Equal(l.Recoverings(), 4)
+
+	// Spaced restarts only count inside the window.
+	backoffs = nil
+	policy.Window = 80 * time.Millisecond
+	policy.Backoff = func(n int) time.Duration {
+		backoffs = append(backoffs, n)
+		return 50 * time.Millisecond
+	}
+	l = loop.GoRestartable(makeStabilizingLF(4, donec), policy, "restartable")
+
+	assert.Wait(donec, "stable", 5*longTimeout)
+	assert.Equal(backoffs, []int{1, 2, 2, 2})
+	assert.Nil(l.Stop())
+
+	// Limit without window.
+	policy = loop.RestartPolicy{MaxRestarts: 1}
+	l = loop.GoRestartable(makeStabilizingLF(2, donec), policy, "restartable")
+
+	assert.ErrorMatch(l.Wait(), `.*loop exceeded limit of 1 restarts: failure 2`)
+}
+
 // TestDescription tests the handling of loop and
 // sentinel descriptions.
 func TestDescription(t *testing.T) {
@@ -203,6 +342,58 @@ func TestDescription(t *testing.T) {
 	assert.Nil(s.Stop())
 }
 
+// TestGroupStop tests stopping a group of loops.
+func TestGroupStop(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	g := loop.NewGroup(longTimeout)
+	la := loop.Go(makeSimpleLF(donec), "group-stop", "a")
+	lb := loop.Go(makeSimpleLF(donec), "group-stop", "b")
+	g.Add(la, lb)
+
+	assert.Nil(g.Stop())
+	assert.Wait(donec, true, shortTimeout)
+	assert.Wait(donec, true, shortTimeout)
+
+	// Failing and stubborn loops.
+	g = loop.NewGroup(longTimeout)
+	la = loop.Go(makeSimpleLF(donec), "group-stop", "a")
+	lb = loop.Go(makeDeferredErrorLF(donec), "group-stop", "b")
+	lc := loop.Go(makeStubbornLF(stayCalm), "group-stop", "c")
+	g.Add(la)
+	g.Add(lb, lc)
+
+	err := g.Stop()
+	errs := golerrors.All(err)
+	assert.Length(errs, 2)
+	assert.ErrorMatch(errs[0], `.*loop "group-stop::b" of group failed: deferred error`)
+	assert.ErrorMatch(errs[1], `.*loop "group-stop::c" of group did not stop in time`)
+	assert.Wait(donec, true, shortTimeout)
+	assert.Wait(donec, true, shortTimeout)
+}
+
+// TestGroupWait tests waiting for a group of loops.
+func TestGroupWait(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	donec := audit.MakeSigChan()
+	g := loop.NewGroup(0)
+	la := loop.Go(makeSimpleLF(donec), "group-wait", "a")
+	lb := loop.Go(makeErrorLF(donec), "group-wait", "b")
+	g.Add(la, lb)
+
+	go func() {
+		time.Sleep(shortTimeout)
+		la.Stop()
+	}()
+
+	err := g.Wait()
+	errs := golerrors.All(err)
+	assert.Length(errs, 1)
+	assert.ErrorMatch(errs[0], `.*loop "group-wait::b" of group failed: timed out`)
+	assert.Wait(donec, true, shortTimeout)
+	assert.Wait(donec, true, shortTimeout)
+}
+
 // TestSimpleSentinel tests the simple starting and
 // stopping of a sentinel.
 func TestSimpleSentinel(t *testing.T) {
@@ -605,6 +796,35 @@ func makeSimpleLF(donec chan interface{}) loop.LoopFunc {
 	}
 }
 
+func makeContextF(donec chan interface{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		donec <- ctx.Err()
+		return nil
+	}
+}
+
+func makeStubbornLF(delay time.Duration) loop.LoopFunc {
+	return func(l loop.Loop) error {
+		<-l.ShallStop()
+		time.Sleep(delay)
+		return nil
+	}
+}
+
+func makeStabilizingLF(failures int, donec chan interface{}) loop.LoopFunc {
+	runs := 0
+	return func(l loop.Loop) error {
+		runs++
+		if runs <= failures {
+			return fmt.Errorf("failure %d", runs)
+		}
+		donec <- "stable"
+		<-l.ShallStop()
+		return nil
+	}
+}
+
 func makeStartStopLF(infoc chan interface{}) loop.LoopFunc {
 	return func(l loop.Loop) error {
 		defer func() { infoc <- "stopped" }()
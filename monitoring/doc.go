@@ -14,6 +14,36 @@
 // nothing, and own implementations can integrate external systems.
 // Additionally filters can be added to reduce the monitoring to
 // the points of interest.
+//
+// The measuring points of the StandardBackend also provide percentiles
+// of the execution times. They are computed out of a uniform random
+// sample of 1024 measurings per point (reservoir sampling). This keeps
+// the memory constant but makes the percentiles estimations once more
+// measurings are done. Here older and recent measurings are equally
+// weighted and rare outliers above the p99 may not be sampled.
+//
+// RateVariable() returns the increments per second of a stay-set
+// variable during a sliding window, its size can be changed with
+// SetRateWindow().
+//
+// Gauges are registered with RegisterGauge(). Their functions are
+// called each time the gauges are read, panics are logged and don't
+// affect the backend.
+//
+// Snapshot() returns a consistent copy of all measuring points and
+// stay-set variables at one point in time, it can be marshalled
+// to JSON.
+//
+// NewPrometheusHandler() returns a HTTP handler rendering the measuring
+// points including their percentiles, the stay-set variables, and
+// the gauges in the Prometheus text format.
+//
+// Own backends only have to implement the Backend interface, their
+// measuring points may additionally implement DetailedMeasuringPoint.
+// Further functionality is provided by the optional interfaces
+// ResettingBackend, RateBackend, GaugeBackend, and SnapshotBackend.
+// Without them the according functions return an error or, like the
+// gauges, behave as if nothing is registered.
 package monitoring
 
 // EOF
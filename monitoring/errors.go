@@ -25,6 +25,9 @@ const (
 	ErrMeasuringPointNotExists
 	ErrStaySetVariableNotExists
 	ErrDynamicStatusNotExists
+	ErrBackendNotSupported
+	ErrGaugeNotExists
+	ErrGaugePanicked
 )
 
 var errorMessages = errors.Messages{
@@ -33,6 +36,9 @@ var errorMessages = errors.Messages{
 	ErrMeasuringPointNotExists:     "measuring point %q does not exist",
 	ErrStaySetVariableNotExists:    "stay-set variable %q does not exist",
 	ErrDynamicStatusNotExists:      "dynamic status %q does not exist",
+	ErrBackendNotSupported:         "monitoring backend does not support %s",
+	ErrGaugeNotExists:              "gauge %q does not exist",
+	ErrGaugePanicked:               "gauge %q panicked: %v",
 }
 
 //--------------------
@@ -69,4 +75,22 @@ func IsDynamicStatusNotExistsError(err error) bool {
 	return errors.IsError(err, ErrDynamicStatusNotExists)
 }
 
+// IsBackendNotSupportedError returns true, if the error signals that
+// the monitoring backend doesn't support the wanted functionality.
+func IsBackendNotSupportedError(err error) bool {
+	return errors.IsError(err, ErrBackendNotSupported)
+}
+
+// IsGaugeNotExistsError returns true, if the error signals that
+// a wanted gauge cannot be retrieved because it doesn't exists.
+func IsGaugeNotExistsError(err error) bool {
+	return errors.IsError(err, ErrGaugeNotExists)
+}
+
+// IsGaugePanickedError returns true, if the error signals that
+// the function of a gauge panicked.
+func IsGaugePanickedError(err error) bool {
+	return errors.IsError(err, ErrGaugePanicked)
+}
+
 // EOF
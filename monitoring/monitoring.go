@@ -17,6 +17,8 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/tideland/golib/errors"
 )
 
 //--------------------
@@ -76,6 +78,20 @@ type MeasuringPoint interface {
 	AvgDuration() time.Duration
 }
 
+// DetailedMeasuringPoint is a measuring point additionally
+// providing details of the execution times. Backends may return
+// measuring points only implementing MeasuringPoint.
+type DetailedMeasuringPoint interface {
+	MeasuringPoint
+
+	// TotalDuration returns the sum of all execution times.
+	TotalDuration() time.Duration
+
+	// Percentile returns the execution time below which the passed
+	// percentage of the measurings fall, e.g. 99 for the p99.
+	Percentile(p float64) time.Duration
+}
+
 // MeasuringPoints is a set of measuring points.
 type MeasuringPoints []MeasuringPoint
 
@@ -142,6 +158,30 @@ func (d DynamicStatusValues) Len() int           { return len(d) }
 func (d DynamicStatusValues) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 func (d DynamicStatusValues) Less(i, j int) bool { return d[i].ID() < d[j].ID() }
 
+// GaugeFunc is called when reading a gauge and returns
+// its current value.
+type GaugeFunc func() float64
+
+// Gauge contains the value of a gauge read on demand.
+type Gauge interface {
+	fmt.Stringer
+
+	// ID returns the identifier of the gauge.
+	ID() string
+
+	// Value returns the read value of the gauge.
+	Value() float64
+}
+
+// Gauges is a set of gauges.
+type Gauges []Gauge
+
+// Implement the sort interface.
+
+func (g Gauges) Len() int           { return len(g) }
+func (g Gauges) Swap(i, j int)      { g[i], g[j] = g[j], g[i] }
+func (g Gauges) Less(i, j int) bool { return g[i].ID() < g[j].ID() }
+
 // Backend defines the interface for a type managing all
 // the information provided or needed by the public functions
 // of the monitoring package.
@@ -201,6 +241,60 @@ type Backend interface {
 	Stop()
 }
 
+// ResettingBackend is a backend additionally allowing to
+// reset single monitored values.
+type ResettingBackend interface {
+	Backend
+
+	// ResetMeasuring removes the measuring point for an id.
+	ResetMeasuring(id string) error
+
+	// ResetVariable removes the stay-set variable for an id.
+	ResetVariable(id string) error
+
+	// ResetAll removes all measuring points and stay-set
+	// variables but keeps the status retrievers.
+	ResetAll() error
+}
+
+// RateBackend is a backend additionally providing the rates
+// of stay-set variables.
+type RateBackend interface {
+	Backend
+
+	// RateVariable returns the increments per second of a
+	// stay-set variable during the rate window.
+	RateVariable(id string) (float64, error)
+
+	// SetRateWindow sets the duration of the sliding window for
+	// the rates of stay-set variables and returns the current one.
+	SetRateWindow(window time.Duration) time.Duration
+}
+
+// GaugeBackend is a backend additionally managing gauges.
+type GaugeBackend interface {
+	Backend
+
+	// RegisterGauge registers a new gauge function.
+	RegisterGauge(id string, gf GaugeFunc)
+
+	// ReadGauge returns the current value of a gauge.
+	ReadGauge(id string) (float64, error)
+
+	// GaugesDo performs the function f for all gauges.
+	GaugesDo(f func(Gauge)) error
+}
+
+// SnapshotBackend is a backend additionally providing
+// consistent snapshots.
+type SnapshotBackend interface {
+	Backend
+
+	// Snapshot returns a consistent copy of all measuring points
+	// and stay-set variables.
+	Snapshot() (*RegistrySnapshot, error)
+}
+
 //--------------------
 // MONITORING API
 //--------------------
@@ -328,6 +422,35 @@ func StaySetVariablesDo(f func(StaySetVariable)) error {
 	return monitor.backend().StaySetVariablesDo(f)
 }
 
+// RateVariable returns the increments per second of a stay-set
+// variable during the sliding rate window. Only increments with
+// IncrVariable are counted. Without them during the window the
+// rate is zero. Backends not implementing RateBackend return
+// an error.
+func RateVariable(id string) (float64, error) {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	rb, ok := monitor.backend().(RateBackend)
+	if !ok {
+		return 0, errors.New(ErrBackendNotSupported, errorMessages, "rates")
+	}
+	return rb.RateVariable(id)
+}
+
+// SetRateWindow sets the duration of the sliding window for the
+// rates of stay-set variables and returns the current one. Changing
+// it restarts the rates. Backends not implementing RateBackend
+// ignore it and return 0.
+func SetRateWindow(window time.Duration) time.Duration {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	rb, ok := monitor.backend().(RateBackend)
+	if !ok {
+		return 0
+	}
+	return rb.SetRateWindow(window)
+}
+
 // StaySetVariablesWrite prints the stay-set variables for which
 // the passed function returns true to the passed writer.
 func StaySetVariablesWrite(w io.Writer, ff func(StaySetVariable) bool) error {
@@ -395,6 +518,54 @@ func DynamicStatusValuesPrintAll() error {
 	return DynamicStatusValuesWrite(os.Stdout, func(dsv DynamicStatusValue) bool { return true })
 }
 
+// RegisterGauge registers a new gauge function. It is called
+// each time the gauge is read. Panics of it are logged and
+// lead to an error. Backends not implementing GaugeBackend
+// ignore it, so they have no gauges.
+func RegisterGauge(id string, gf GaugeFunc) {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	if gb, ok := monitor.backend().(GaugeBackend); ok {
+		gb.RegisterGauge(id, gf)
+	}
+}
+
+// ReadGauge returns the current value of a gauge.
+func ReadGauge(id string) (float64, error) {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	gb, ok := monitor.backend().(GaugeBackend)
+	if !ok {
+		return 0, errors.New(ErrGaugeNotExists, errorMessages, id)
+	}
+	return gb.ReadGauge(id)
+}
+
+// GaugesDo performs the function f for all gauges. Those
+// which panic are logged and skipped.
+func GaugesDo(f func(Gauge)) error {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	gb, ok := monitor.backend().(GaugeBackend)
+	if !ok {
+		return nil
+	}
+	return gb.GaugesDo(f)
+}
+
+// Snapshot returns a consistent copy of all measuring points and
+// stay-set variables taken at one point in time. Backends not
+// implementing SnapshotBackend return an error.
+func Snapshot() (*RegistrySnapshot, error) {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	sb, ok := monitor.backend().(SnapshotBackend)
+	if !ok {
+		return nil, errors.New(ErrBackendNotSupported, errorMessages, "snapshots")
+	}
+	return sb.Snapshot()
+}
+
 // SetMeasuringFilter sets the new filter for measurings
 // and returns the current one.
 func SetMeasuringsFilter(f IDFilter) IDFilter {
@@ -426,4 +597,43 @@ func Reset() error {
 	return monitor.backend().Reset()
 }
 
+// ResetMeasuring removes the measuring point for an id. Measurings
+// ended before are processed first, so they don't show up again.
+// Backends not implementing ResettingBackend return an error.
+func ResetMeasuring(id string) error {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	rb, ok := monitor.backend().(ResettingBackend)
+	if !ok {
+		return errors.New(ErrBackendNotSupported, errorMessages, "resetting single values")
+	}
+	return rb.ResetMeasuring(id)
+}
+
+// ResetVariable removes the stay-set variable for an id. Changes
+// done before are processed first, so they don't show up again.
+// Backends not implementing ResettingBackend return an error.
+func ResetVariable(id string) error {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	rb, ok := monitor.backend().(ResettingBackend)
+	if !ok {
+		return errors.New(ErrBackendNotSupported, errorMessages, "resetting single values")
+	}
+	return rb.ResetVariable(id)
+}
+
+// ResetAll removes all measuring points and stay-set variables.
+// Different to Reset the dynamic status retrievers are kept.
+// Backends not implementing ResettingBackend return an error.
+func ResetAll() error {
+	monitor.RLock()
+	defer monitor.RUnlock()
+	rb, ok := monitor.backend().(ResettingBackend)
+	if !ok {
+		return errors.New(ErrBackendNotSupported, errorMessages, "resetting single values")
+	}
+	return rb.ResetAll()
+}
+
 // EOF
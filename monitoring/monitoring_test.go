@@ -12,8 +12,15 @@ package monitoring_test
 //--------------------
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -54,6 +61,41 @@ func TestETMMonitor(t *testing.T) {
 	})
 }
 
+// TestPercentiles tests the percentiles of measuring points.
+func TestPercentiles(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	// Generate measurings.
+	for i := 1; i <= 10; i++ {
+		monitoring.Measure("percentiles:sleep", func() { time.Sleep(time.Duration(i) * time.Millisecond) })
+	}
+	for i := 0; i < 5000; i++ {
+		monitoring.Measure("percentiles:work", func() { work(rand.Intn(10) * 5000) })
+	}
+	// Need some time to let that backend catch up queued mesurings.
+	time.Sleep(time.Millisecond)
+	// Asserts.
+	mp, err := monitoring.ReadMeasuringPoint("percentiles:sleep")
+	assert.Nil(err)
+	dmp, ok := mp.(monitoring.DetailedMeasuringPoint)
+	assert.True(ok)
+	p50 := dmp.Percentile(50)
+	p90 := dmp.Percentile(90)
+	p99 := dmp.Percentile(99)
+	assert.True(5*time.Millisecond <= p50 && p50 < 10*time.Millisecond, "p50 in range")
+	assert.True(9*time.Millisecond <= p90 && p90 <= p99, "p90 in range")
+	assert.Equal(p99, mp.MaxDuration())
+	assert.Equal(dmp.Percentile(0), mp.MinDuration())
+	mp, err = monitoring.ReadMeasuringPoint("percentiles:work")
+	assert.Nil(err)
+	assert.Equal(mp.Count(), int64(5000))
+	dmp, ok = mp.(monitoring.DetailedMeasuringPoint)
+	assert.True(ok)
+	p50 = dmp.Percentile(50)
+	p99 = dmp.Percentile(99)
+	assert.True(mp.MinDuration() <= p50 && p50 <= p99 && p99 <= mp.MaxDuration(), "percentiles between min and max")
+}
+
 // Test of the SSI monitor.
 func TestSSIMonitor(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -81,6 +123,46 @@ func TestSSIMonitor(t *testing.T) {
 	})
 }
 
+// TestRateVariable tests the rates of stay-set variables.
+func TestRateVariable(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	window := monitoring.SetRateWindow(100 * time.Millisecond)
+	assert.Equal(window, 10*time.Second)
+	// Generate increments.
+	for i := 0; i < 50; i++ {
+		monitoring.IncrVariable("rate:a")
+	}
+	monitoring.DecrVariable("rate:a")
+	monitoring.SetVariable("rate:b", 10)
+	// Asserts.
+	rate, err := monitoring.RateVariable("rate:a")
+	assert.Nil(err)
+	assert.About(rate, 500.0, 0.1)
+	rate, err = monitoring.RateVariable("rate:b")
+	assert.Nil(err)
+	assert.Equal(rate, 0.0)
+	_, err = monitoring.RateVariable("rate:c")
+	assert.True(monitoring.IsStaySetVariableNotExistsError(err))
+	// No activity leads to zero.
+	time.Sleep(150 * time.Millisecond)
+	rate, err = monitoring.RateVariable("rate:a")
+	assert.Nil(err)
+	assert.Equal(rate, 0.0)
+	// Changing the window restarts the rates.
+	monitoring.IncrVariable("rate:a")
+	window = monitoring.SetRateWindow(time.Second)
+	assert.Equal(window, 100*time.Millisecond)
+	rate, err = monitoring.RateVariable("rate:a")
+	assert.Nil(err)
+	assert.Equal(rate, 0.0)
+	monitoring.IncrVariable("rate:a")
+	monitoring.IncrVariable("rate:a")
+	rate, err = monitoring.RateVariable("rate:a")
+	assert.Nil(err)
+	assert.Equal(rate, 2.0)
+}
+
 // Test of the DSR monitor.
 func TestDSRMonitor(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -103,6 +185,103 @@ func TestDSRMonitor(t *testing.T) {
 	assert.ErrorMatch(err, `.* monitoring backend panicked`)
 }
 
+// TestGauges tests the reading of gauges.
+func TestGauges(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	queue := []int{1, 2, 3}
+	monitoring.RegisterGauge("gauge:queue", func() float64 { return float64(len(queue)) })
+	monitoring.RegisterGauge("gauge:pi", func() float64 { return 3.1415 })
+	monitoring.RegisterGauge("gauge:panic", func() float64 { panic("ouch") })
+	// Asserts.
+	value, err := monitoring.ReadGauge("gauge:queue")
+	assert.Nil(err)
+	assert.Equal(value, 3.0)
+	queue = append(queue, 4)
+	value, err = monitoring.ReadGauge("gauge:queue")
+	assert.Nil(err)
+	assert.Equal(value, 4.0)
+	_, err = monitoring.ReadGauge("foo")
+	assert.True(monitoring.IsGaugeNotExistsError(err))
+	_, err = monitoring.ReadGauge("gauge:panic")
+	assert.True(monitoring.IsGaugePanickedError(err))
+	assert.ErrorMatch(err, `.* gauge "gauge:panic" panicked: ouch`)
+	ids := []string{}
+	err = monitoring.GaugesDo(func(g monitoring.Gauge) {
+		ids = append(ids, g.ID())
+	})
+	assert.Nil(err)
+	assert.Equal(ids, []string{"gauge:pi", "gauge:queue"})
+	// Backend still works after the panic.
+	monitoring.IncrVariable("gauge:counter")
+	ssv, err := monitoring.ReadVariable("gauge:counter")
+	assert.Nil(err)
+	assert.Equal(ssv.ActValue(), int64(1))
+	var buf bytes.Buffer
+	assert.Nil(monitoring.PrometheusWrite(&buf))
+	assert.Substring("# TYPE gauge_pi gauge\ngauge_pi 3.1415\n", buf.String())
+}
+
+// TestSnapshot tests the snapshot of the registry.
+func TestSnapshot(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	monitoring.Measure("snapshot:b", func() {})
+	monitoring.Measure("snapshot:a", func() {})
+	monitoring.Measure("snapshot:a", func() {})
+	monitoring.SetVariable("snapshot:v", 10)
+	monitoring.IncrVariable("snapshot:v")
+	// Asserts.
+	snapshot, err := monitoring.Snapshot()
+	assert.Nil(err)
+	assert.Length(snapshot.MeasuringPoints, 2)
+	assert.Equal(snapshot.MeasuringPoints[0].ID(), "snapshot:a")
+	assert.Equal(snapshot.MeasuringPoints[0].Count(), int64(2))
+	assert.Equal(snapshot.MeasuringPoints[1].ID(), "snapshot:b")
+	assert.Length(snapshot.StaySetVariables, 1)
+	assert.Equal(snapshot.StaySetVariables[0].ActValue(), int64(11))
+	// Snapshot stays unchanged.
+	monitoring.IncrVariable("snapshot:v")
+	monitoring.Measure("snapshot:a", func() {})
+	// Need some time to let that backend catch up queued values.
+	time.Sleep(time.Millisecond)
+	ssv, err := monitoring.ReadVariable("snapshot:v")
+	assert.Nil(err)
+	assert.Equal(ssv.ActValue(), int64(12))
+	assert.Equal(snapshot.StaySetVariables[0].ActValue(), int64(11))
+	assert.Equal(snapshot.MeasuringPoints[0].Count(), int64(2))
+	// JSON.
+	data, err := json.Marshal(snapshot)
+	assert.Nil(err)
+	var decoded struct {
+		Time            time.Time `json:"time"`
+		MeasuringPoints []struct {
+			ID          string `json:"id"`
+			Count       int64  `json:"count"`
+			P99Duration int64  `json:"p99Duration"`
+		} `json:"measuringPoints"`
+		StaySetVariables []struct {
+			ID       string `json:"id"`
+			Count    int64  `json:"count"`
+			ActValue int64  `json:"actValue"`
+			MaxValue int64  `json:"maxValue"`
+		} `json:"staySetVariables"`
+	}
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.True(decoded.Time.Equal(snapshot.Time))
+	assert.Length(decoded.MeasuringPoints, 2)
+	assert.Equal(decoded.MeasuringPoints[0].ID, "snapshot:a")
+	assert.Equal(decoded.MeasuringPoints[0].Count, int64(2))
+	dmp, ok := snapshot.MeasuringPoints[0].(monitoring.DetailedMeasuringPoint)
+	assert.True(ok)
+	assert.Equal(decoded.MeasuringPoints[0].P99Duration, int64(dmp.Percentile(99)))
+	assert.Length(decoded.StaySetVariables, 1)
+	assert.Equal(decoded.StaySetVariables[0].ID, "snapshot:v")
+	assert.Equal(decoded.StaySetVariables[0].Count, int64(2))
+	assert.Equal(decoded.StaySetVariables[0].ActValue, int64(11))
+	assert.Equal(decoded.StaySetVariables[0].MaxValue, int64(11))
+}
+
 // TestStandardInternalPanic tests the clean handling of panics
 // when retrieving a status with the standard backend.
 func TestInternalPanic(t *testing.T) {
@@ -118,6 +297,76 @@ func TestInternalPanic(t *testing.T) {
 	assert.ErrorMatch(err, `.* monitoring backend panicked`)
 }
 
+// TestReset tests resetting single and all values.
+func TestReset(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	monitoring.Register("reset:status", func() (string, error) { return "ok", nil })
+	monitoring.Measure("reset:a", func() {})
+	monitoring.Measure("reset:b", func() {})
+	monitoring.IncrVariable("reset:a")
+	monitoring.IncrVariable("reset:b")
+	// Reset single values.
+	assert.Nil(monitoring.ResetMeasuring("reset:a"))
+	assert.Nil(monitoring.ResetVariable("reset:a"))
+	_, err := monitoring.ReadMeasuringPoint("reset:a")
+	assert.True(monitoring.IsMeasuringPointNotExistsError(err))
+	_, err = monitoring.ReadVariable("reset:a")
+	assert.True(monitoring.IsStaySetVariableNotExistsError(err))
+	mp, err := monitoring.ReadMeasuringPoint("reset:b")
+	assert.Nil(err)
+	assert.Equal(mp.Count(), int64(1))
+	ssv, err := monitoring.ReadVariable("reset:b")
+	assert.Nil(err)
+	assert.Equal(ssv.ActValue(), int64(1))
+	// Reset all values.
+	assert.Nil(monitoring.ResetAll())
+	_, err = monitoring.ReadMeasuringPoint("reset:b")
+	assert.True(monitoring.IsMeasuringPointNotExistsError(err))
+	_, err = monitoring.ReadVariable("reset:b")
+	assert.True(monitoring.IsStaySetVariableNotExistsError(err))
+	status, err := monitoring.ReadStatus("reset:status")
+	assert.Nil(err)
+	assert.Equal(status, "ok")
+}
+
+// TestConcurrentReset tests resetting while values are changed
+// concurrently.
+func TestConcurrentReset(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("concurrent:%d", i)
+			for j := 0; j < 500; j++ {
+				monitoring.IncrVariable(id)
+				monitoring.Measure(id, func() {})
+			}
+			monitoring.ResetVariable(id)
+			monitoring.ResetMeasuring(id)
+			for j := 0; j < 100; j++ {
+				monitoring.IncrVariable(id)
+				monitoring.Measure(id, func() {})
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Need some time to let that backend catch up queued values.
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("concurrent:%d", i)
+		ssv, err := monitoring.ReadVariable(id)
+		assert.Nil(err)
+		assert.Equal(ssv.ActValue(), int64(100), id)
+		mp, err := monitoring.ReadMeasuringPoint(id)
+		assert.Nil(err)
+		assert.Equal(mp.Count(), int64(100), id)
+	}
+}
+
 // TestBackendSwitch tests the correct switching between backends.
 func TestBackendSwitch(t *testing.T) {
 	assert := audit.NewTestingAssertion(t, true)
@@ -149,6 +398,111 @@ func TestBackendSwitch(t *testing.T) {
 	assert.True(sleep <= mp.AvgDuration() && mp.AvgDuration() <= 2*sleep)
 }
 
+// TestPlainBackend tests a backend only implementing the
+// Backend interface without the optional ones.
+func TestPlainBackend(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(plainBackend{monitoring.NewStandardBackend()})
+	defer monitoring.SetBackend(monitoring.NewStandardBackend())
+	monitoring.Measure("plain:mp", func() {})
+	monitoring.IncrVariable("plain:ssv")
+	time.Sleep(time.Millisecond)
+	// Optional functionality.
+	_, err := monitoring.RateVariable("plain:ssv")
+	assert.True(monitoring.IsBackendNotSupportedError(err))
+	assert.Equal(monitoring.SetRateWindow(time.Second), time.Duration(0))
+	monitoring.RegisterGauge("plain:gauge", func() float64 { return 1.0 })
+	_, err = monitoring.ReadGauge("plain:gauge")
+	assert.True(monitoring.IsGaugeNotExistsError(err))
+	_, err = monitoring.Snapshot()
+	assert.ErrorMatch(err, `.* monitoring backend does not support snapshots`)
+	assert.True(monitoring.IsBackendNotSupportedError(monitoring.ResetMeasuring("plain:mp")))
+	assert.True(monitoring.IsBackendNotSupportedError(monitoring.ResetVariable("plain:ssv")))
+	assert.True(monitoring.IsBackendNotSupportedError(monitoring.ResetAll()))
+	// Exposition without details and gauges.
+	var buf bytes.Buffer
+	assert.Nil(monitoring.PrometheusWrite(&buf))
+	assert.Substring("plain_mp_duration_seconds_sum ", buf.String())
+	assert.Substring("plain_mp_duration_seconds_count 1\n", buf.String())
+	assert.Substring("plain_ssv_changes_total 1\n", buf.String())
+	assert.False(strings.Contains(buf.String(), "quantile"))
+	assert.False(strings.Contains(buf.String(), "plain_gauge"))
+}
+
+// TestPrometheusHandler tests scraping the monitoring values
+// in the Prometheus text format.
+func TestPrometheusHandler(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	monitoring.Measure("prom:task", func() { time.Sleep(time.Millisecond) })
+	monitoring.Measure("prom:task", func() { time.Sleep(time.Millisecond) })
+	monitoring.SetVariable("prom:queue-length", 5)
+	monitoring.SetVariable("prom:queue-length", 3)
+	// Need some time to let that backend catch up queued values.
+	time.Sleep(time.Millisecond)
+	// Scrape the handler.
+	server := httptest.NewServer(monitoring.NewPrometheusHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(resp.StatusCode, http.StatusOK)
+	assert.Match(resp.Header.Get("Content-Type"), `text/plain; version=0\.0\.4.*`)
+	// Asserts.
+	metrics := string(body)
+	assert.Substring("# TYPE prom_task_duration_seconds summary\n", metrics)
+	assert.Substring("prom_task_duration_seconds_count 2\n", metrics)
+	assert.Match(metrics, `(?s).*\nprom_task_duration_seconds\{quantile="0\.99"\} 0\.00[0-9]+\n.*`)
+	assert.Match(metrics, `(?s).*\nprom_task_duration_seconds_sum 0\.00[0-9]+\n.*`)
+	assert.Substring("# TYPE prom_task_duration_seconds_min gauge\n", metrics)
+	assert.Substring("# TYPE prom_task_duration_seconds_max gauge\n", metrics)
+	assert.Substring("# TYPE prom_queue_length gauge\nprom_queue_length 3\n", metrics)
+	assert.Substring("prom_queue_length_min 3\n", metrics)
+	assert.Substring("prom_queue_length_max 5\n", metrics)
+	assert.Substring("# TYPE prom_queue_length_changes_total counter\nprom_queue_length_changes_total 2\n", metrics)
+	for _, line := range strings.Split(strings.TrimSpace(metrics), "\n") {
+		assert.Match(line, `(# TYPE [a-zA-Z_:][a-zA-Z0-9_:]* (gauge|counter|summary))|([a-zA-Z_:][a-zA-Z0-9_:]*(\{quantile="[0-9.]+"\})? [0-9.e+-]+)`)
+	}
+}
+
+// TestPrometheusCollisions tests that colliding metric names
+// are written only once.
+func TestPrometheusCollisions(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	monitoring.SetBackend(monitoring.NewStandardBackend())
+	monitoring.Measure("coll.task", func() {})
+	monitoring.Measure("coll:task", func() {})
+	monitoring.SetVariable("coll:x", 1)
+	monitoring.RegisterGauge("coll:x:min", func() float64 { return 2.0 })
+	monitoring.RegisterGauge("coll:y", func() float64 { return 3.0 })
+	time.Sleep(time.Millisecond)
+	var buf bytes.Buffer
+	assert.Nil(monitoring.PrometheusWrite(&buf))
+	metrics := buf.String()
+	assert.Equal(strings.Count(metrics, "# TYPE coll_task_duration_seconds summary\n"), 1)
+	assert.Equal(strings.Count(metrics, "# TYPE coll_x_min gauge\n"), 1)
+	assert.Substring("coll_x_min 1\n", metrics)
+	assert.Substring("coll_y 3\n", metrics)
+	types := map[string]bool{}
+	for _, line := range strings.Split(metrics, "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			assert.False(types[line], line)
+			types[line] = true
+		}
+	}
+}
+
+// TestPrometheusName tests the conversion of IDs into metric names.
+func TestPrometheusName(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	assert.Equal(monitoring.PrometheusName("mp:task:5"), "mp_task_5")
+	assert.Equal(monitoring.PrometheusName("Cell::Some-Behavior"), "cell_some_behavior")
+	assert.Equal(monitoring.PrometheusName("4711/foo"), "_4711_foo")
+	assert.Equal(monitoring.PrometheusName("::"), "_")
+}
+
 //--------------------
 // BENCHMARKS
 //--------------------
@@ -191,6 +545,23 @@ func BenchmarkNullBackendETM(b *testing.B) {
 // HELPERS
 //--------------------
 
+// plainBackend only implements the Backend interface and
+// returns plain measuring points.
+type plainBackend struct {
+	monitoring.Backend
+}
+
+func (b plainBackend) MeasuringPointsDo(f func(monitoring.MeasuringPoint)) error {
+	return b.Backend.MeasuringPointsDo(func(mp monitoring.MeasuringPoint) {
+		f(plainMeasuringPoint{mp})
+	})
+}
+
+// plainMeasuringPoint only implements the MeasuringPoint interface.
+type plainMeasuringPoint struct {
+	monitoring.MeasuringPoint
+}
+
 // Do some work.
 func work(n int) int {
 	if n < 0 {
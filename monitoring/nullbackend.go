@@ -47,6 +47,12 @@ func (mp *nullMeasuringPoint) MaxDuration() time.Duration { return 0 }
 // AvgDuration implements the MeasuringPoint interface.
 func (mp *nullMeasuringPoint) AvgDuration() time.Duration { return 0 }
 
+// TotalDuration implements the DetailedMeasuringPoint interface.
+func (mp *nullMeasuringPoint) TotalDuration() time.Duration { return 0 }
+
+// Percentile implements the DetailedMeasuringPoint interface.
+func (mp *nullMeasuringPoint) Percentile(p float64) time.Duration { return 0 }
+
 // String implements the Stringer interface.
 func (mp *nullMeasuringPoint) String() string { return "Null Measuring Point" }
 
@@ -132,6 +138,12 @@ func (b *nullBackend) ReadVariable(id string) (StaySetVariable, error) {
 // StaySetVariablesDo implements the MonitorBackend interface.
 func (b *nullBackend) StaySetVariablesDo(f func(StaySetVariable)) error { return nil }
 
+// RateVariable implements the RateBackend interface.
+func (b *nullBackend) RateVariable(id string) (float64, error) { return 0, nil }
+
+// SetRateWindow implements the RateBackend interface.
+func (b *nullBackend) SetRateWindow(window time.Duration) time.Duration { return 0 }
+
 // Register implements the MonitorBackend interface.
 func (b *nullBackend) Register(id string, rf DynamicStatusRetriever) {}
 
@@ -141,6 +153,20 @@ func (b *nullBackend) ReadStatus(id string) (string, error) { return "", nil }
 // DynamicStatusValuesDo implements the MonitorBackend interface.
 func (b *nullBackend) DynamicStatusValuesDo(f func(DynamicStatusValue)) error { return nil }
 
+// RegisterGauge implements the GaugeBackend interface.
+func (b *nullBackend) RegisterGauge(id string, gf GaugeFunc) {}
+
+// ReadGauge implements the GaugeBackend interface.
+func (b *nullBackend) ReadGauge(id string) (float64, error) { return 0, nil }
+
+// GaugesDo implements the GaugeBackend interface.
+func (b *nullBackend) GaugesDo(f func(Gauge)) error { return nil }
+
+// Snapshot implements the SnapshotBackend interface.
+func (b *nullBackend) Snapshot() (*RegistrySnapshot, error) {
+	return &RegistrySnapshot{Time: time.Now()}, nil
+}
+
 // SetMeasuringsFilter implements the MonitorBackend interface.
 func (b *nullBackend) SetMeasuringsFilter(f IDFilter) IDFilter { return nil }
 
@@ -153,6 +179,15 @@ func (b *nullBackend) SetRetrieversFilter(f IDFilter) IDFilter { return nil }
 // Reset implements the MonitorBackend interface.
 func (b *nullBackend) Reset() error { return nil }
 
+// ResetMeasuring implements the ResettingBackend interface.
+func (b *nullBackend) ResetMeasuring(id string) error { return nil }
+
+// ResetVariable implements the ResettingBackend interface.
+func (b *nullBackend) ResetVariable(id string) error { return nil }
+
+// ResetAll implements the ResettingBackend interface.
+func (b *nullBackend) ResetAll() error { return nil }
+
 // Stop implements the MonitorBackend interface.
 func (b *nullBackend) Stop() {}
 
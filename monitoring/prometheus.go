@@ -0,0 +1,158 @@
+// Tideland Go Library - Monitoring - Prometheus
+//
+// Copyright (C) 2009-2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package monitoring
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tideland/golib/identifier"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// prometheusContentType is the content type of the Prometheus
+// text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//--------------------
+// PROMETHEUS
+//--------------------
+
+// prometheusQuantiles are the quantiles exported for the
+// measuring points.
+var prometheusQuantiles = []float64{0.5, 0.9, 0.99}
+
+// PrometheusWrite writes the measuring points, stay-set variables,
+// and gauges in the Prometheus text format to the passed writer. The metric names
+// are derived from the parts of the IDs, e.g. the measuring point
+// "mp:task:5" leads to "mp_task_5_duration_seconds". For measuring
+// points not implementing DetailedMeasuringPoint the quantiles are
+// omitted and the sum is derived from the average duration. Metrics
+// with names colliding with already written ones, e.g. for the IDs
+// "a.b" and "a:b", are skipped.
+func PrometheusWrite(w io.Writer) error {
+	names := prometheusNames{}
+	if err := MeasuringPointsDo(func(mp MeasuringPoint) {
+		name := PrometheusName(mp.ID()) + "_duration_seconds"
+		if !names.reserve(name, name+"_sum", name+"_count", name+"_min", name+"_max") {
+			return
+		}
+		fmt.Fprintf(w, "# TYPE %s summary\n", name)
+		total := mp.AvgDuration() * time.Duration(mp.Count())
+		if dmp, ok := mp.(DetailedMeasuringPoint); ok {
+			for _, q := range prometheusQuantiles {
+				fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n", name, strconv.FormatFloat(q, 'g', -1, 64), formatSeconds(dmp.Percentile(q*100)))
+			}
+			total = dmp.TotalDuration()
+		}
+		fmt.Fprintf(w, "%s_sum %s\n", name, formatSeconds(total))
+		fmt.Fprintf(w, "%s_count %d\n", name, mp.Count())
+		writePrometheusMetric(w, name+"_min", "gauge", formatSeconds(mp.MinDuration()))
+		writePrometheusMetric(w, name+"_max", "gauge", formatSeconds(mp.MaxDuration()))
+	}); err != nil {
+		return err
+	}
+	if err := StaySetVariablesDo(func(ssv StaySetVariable) {
+		name := PrometheusName(ssv.ID())
+		if !names.reserve(name, name+"_min", name+"_max", name+"_changes_total") {
+			return
+		}
+		writePrometheusMetric(w, name, "gauge", strconv.FormatInt(ssv.ActValue(), 10))
+		writePrometheusMetric(w, name+"_min", "gauge", strconv.FormatInt(ssv.MinValue(), 10))
+		writePrometheusMetric(w, name+"_max", "gauge", strconv.FormatInt(ssv.MaxValue(), 10))
+		writePrometheusMetric(w, name+"_changes_total", "counter", strconv.FormatInt(ssv.Count(), 10))
+	}); err != nil {
+		return err
+	}
+	return GaugesDo(func(g Gauge) {
+		name := PrometheusName(g.ID())
+		if !names.reserve(name) {
+			return
+		}
+		writePrometheusMetric(w, name, "gauge", strconv.FormatFloat(g.Value(), 'g', -1, 64))
+	})
+}
+
+// NewPrometheusHandler returns a HTTP handler rendering the
+// monitoring values with PrometheusWrite for scraping.
+func NewPrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := PrometheusWrite(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", prometheusContentType)
+		buf.WriteTo(w)
+	})
+}
+
+// PrometheusName converts a monitoring ID into a valid Prometheus
+// metric name. All characters except letters and digits separate
+// the parts of the ID, they are joined with underscores.
+func PrometheusName(id string) string {
+	fields := strings.FieldsFunc(id, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r)) || r > unicode.MaxASCII
+	})
+	parts := make([]interface{}, len(fields))
+	for i, field := range fields {
+		parts[i] = field
+	}
+	name := identifier.SepIdentifier("_", parts...)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
+
+//--------------------
+// PRIVATE HELPERS
+//--------------------
+
+// prometheusNames contains the metric names already written.
+type prometheusNames map[string]bool
+
+// reserve marks the names of one monitored value as written. It
+// returns false without marking if one of them is already written.
+func (pn prometheusNames) reserve(names ...string) bool {
+	for _, name := range names {
+		if pn[name] {
+			return false
+		}
+	}
+	for _, name := range names {
+		pn[name] = true
+	}
+	return true
+}
+
+// writePrometheusMetric writes a metric with its type.
+func writePrometheusMetric(w io.Writer, name, kind, value string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(w, "%s %s\n", name, value)
+}
+
+// formatSeconds returns a duration as seconds for Prometheus.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// EOF
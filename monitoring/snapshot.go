@@ -0,0 +1,103 @@
+// Tideland Go Library - Monitoring - Snapshot
+//
+// Copyright (C) 2009-2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package monitoring
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//--------------------
+// SNAPSHOT
+//--------------------
+
+// RegistrySnapshot contains the measuring points and stay-set
+// variables of the backend at one point in time.
+type RegistrySnapshot struct {
+	Time             time.Time
+	MeasuringPoints  MeasuringPoints
+	StaySetVariables StaySetVariables
+}
+
+// jsonMeasuringPoint is the JSON representation of a measuring
+// point. The durations are in nanoseconds.
+type jsonMeasuringPoint struct {
+	ID            string `json:"id"`
+	Count         int64  `json:"count"`
+	MinDuration   int64  `json:"minDuration"`
+	MaxDuration   int64  `json:"maxDuration"`
+	AvgDuration   int64  `json:"avgDuration"`
+	TotalDuration int64  `json:"totalDuration"`
+	P50Duration   int64  `json:"p50Duration"`
+	P90Duration   int64  `json:"p90Duration"`
+	P99Duration   int64  `json:"p99Duration"`
+}
+
+// jsonStaySetVariable is the JSON representation of a
+// stay-set variable.
+type jsonStaySetVariable struct {
+	ID       string `json:"id"`
+	Count    int64  `json:"count"`
+	ActValue int64  `json:"actValue"`
+	MinValue int64  `json:"minValue"`
+	MaxValue int64  `json:"maxValue"`
+	AvgValue int64  `json:"avgValue"`
+}
+
+// jsonRegistrySnapshot is the JSON representation of a snapshot.
+type jsonRegistrySnapshot struct {
+	Time             time.Time             `json:"time"`
+	MeasuringPoints  []jsonMeasuringPoint  `json:"measuringPoints"`
+	StaySetVariables []jsonStaySetVariable `json:"staySetVariables"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The
+// durations of the measuring points are written in nanoseconds.
+// The percentiles are zero for measuring points not implementing
+// DetailedMeasuringPoint.
+func (rs *RegistrySnapshot) MarshalJSON() ([]byte, error) {
+	jrs := jsonRegistrySnapshot{
+		Time:             rs.Time,
+		MeasuringPoints:  make([]jsonMeasuringPoint, len(rs.MeasuringPoints)),
+		StaySetVariables: make([]jsonStaySetVariable, len(rs.StaySetVariables)),
+	}
+	for i, mp := range rs.MeasuringPoints {
+		jmp := jsonMeasuringPoint{
+			ID:            mp.ID(),
+			Count:         mp.Count(),
+			MinDuration:   int64(mp.MinDuration()),
+			MaxDuration:   int64(mp.MaxDuration()),
+			AvgDuration:   int64(mp.AvgDuration()),
+			TotalDuration: int64(mp.AvgDuration()) * mp.Count(),
+		}
+		if dmp, ok := mp.(DetailedMeasuringPoint); ok {
+			jmp.TotalDuration = int64(dmp.TotalDuration())
+			jmp.P50Duration = int64(dmp.Percentile(50))
+			jmp.P90Duration = int64(dmp.Percentile(90))
+			jmp.P99Duration = int64(dmp.Percentile(99))
+		}
+		jrs.MeasuringPoints[i] = jmp
+	}
+	for i, ssv := range rs.StaySetVariables {
+		jrs.StaySetVariables[i] = jsonStaySetVariable{
+			ID:       ssv.ID(),
+			Count:    ssv.Count(),
+			ActValue: ssv.ActValue(),
+			MinValue: ssv.MinValue(),
+			MaxValue: ssv.MaxValue(),
+			AvgValue: ssv.AvgValue(),
+		}
+	}
+	return json.Marshal(jrs)
+}
+
+// EOF
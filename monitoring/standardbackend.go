@@ -13,6 +13,8 @@ package monitoring
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"time"
 
@@ -27,14 +29,35 @@ import (
 
 const (
 	cmdReset = iota
+	cmdResetMeasuring
+	cmdResetVariable
+	cmdResetAll
 	cmdMeasuringPointRead
 	cmdMeasuringPointsReadAll
 	cmdStaySetVariableRead
 	cmdStaySetVariablesReadAll
+	cmdStaySetVariableRate
+	cmdSetRateWindow
 	cmdDynamicStatusRetrieverRead
 	cmdDynamicStatusRetrieversReadAll
+	cmdGaugeRegister
+	cmdGaugeRead
+	cmdGaugesReadAll
+	cmdSnapshot
 )
 
+// rateBuckets is the number of buckets the sliding window
+// of the variable rates is divided into.
+const rateBuckets = 60
+
+// defaultRateWindow is the default duration of the sliding
+// window of the variable rates.
+const defaultRateWindow = 10 * time.Second
+
+// reservoirSize is the number of execution times sampled
+// per measuring point for the percentiles.
+const reservoirSize = 1024
+
 //--------------------
 // COMMAND
 //--------------------
@@ -87,13 +110,20 @@ func (m *stdMeasuring) EndMeasuring() time.Duration {
 // MEASURING POINT
 //--------------------
 
-// stdMeasuringPoint implements the MeasuringPoint interface.
+// stdMeasuringPoint implements the MeasuringPoint interface. For
+// the percentiles it keeps a uniform random sample of the execution
+// times with a fixed size (reservoir sampling). So the memory stays
+// constant, but the percentiles are estimations as soon as more than
+// reservoirSize measurings have been done. All measurings since the
+// start or reset have the same weight, recent ones are not preferred.
 type stdMeasuringPoint struct {
 	id          string
 	count       int64
 	minDuration time.Duration
 	maxDuration time.Duration
 	avgDuration time.Duration
+	total       time.Duration
+	samples     []time.Duration
 }
 
 // newStdMeasuringPoint creates a new measuring point out of a measuring.
@@ -104,6 +134,8 @@ func newStdMeasuringPoint(m *stdMeasuring) *stdMeasuringPoint {
 		minDuration: m.duration,
 		maxDuration: m.duration,
 		avgDuration: m.duration,
+		total:       m.duration,
+		samples:     []time.Duration{m.duration},
 	}
 }
 
@@ -122,10 +154,38 @@ func (mp *stdMeasuringPoint) MaxDuration() time.Duration { return mp.maxDuration
 // AvgDuration implements the MeasuringPoint interface.
 func (mp *stdMeasuringPoint) AvgDuration() time.Duration { return mp.avgDuration }
 
+// TotalDuration implements the DetailedMeasuringPoint interface.
+func (mp *stdMeasuringPoint) TotalDuration() time.Duration { return mp.total }
+
+// Percentile implements the DetailedMeasuringPoint interface.
+func (mp *stdMeasuringPoint) Percentile(p float64) time.Duration {
+	if len(mp.samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(mp.samples))
+	copy(sorted, mp.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// Nearest rank.
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	switch {
+	case rank < 1:
+		rank = 1
+	case rank > len(sorted):
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
 // Uupdate a measuring point with a measuring.
-func (mp *stdMeasuringPoint) update(m *stdMeasuring) {
+func (mp *stdMeasuringPoint) update(m *stdMeasuring, rnd *rand.Rand) {
 	average := mp.avgDuration.Nanoseconds()
 	mp.count++
+	mp.total += m.duration
+	if len(mp.samples) < reservoirSize {
+		mp.samples = append(mp.samples, m.duration)
+	} else if i := rnd.Int63n(mp.count); i < reservoirSize {
+		mp.samples[i] = m.duration
+	}
 	if mp.minDuration > m.duration {
 		mp.minDuration = m.duration
 	}
@@ -135,6 +195,15 @@ func (mp *stdMeasuringPoint) update(m *stdMeasuring) {
 	mp.avgDuration = time.Duration((average + m.duration.Nanoseconds()) / 2)
 }
 
+// clone creates a copy of the measuring point not sharing
+// its samples.
+func (mp *stdMeasuringPoint) clone() *stdMeasuringPoint {
+	clone := *mp
+	clone.samples = make([]time.Duration, len(mp.samples))
+	copy(clone.samples, mp.samples)
+	return &clone
+}
+
 // String implements the Stringer interface.
 func (mp *stdMeasuringPoint) String() string {
 	return fmt.Sprintf("Measuring Point %q (%dx / min %s / max %s / avg %s)", mp.id, mp.count, mp.minDuration, mp.maxDuration, mp.avgDuration)
@@ -216,6 +285,62 @@ func (ssv *stdStaySetVariable) String() string {
 		ssv.id, ssv.count, ssv.actValue, ssv.minValue, ssv.maxValue, ssv.avgValue)
 }
 
+//--------------------
+// RATE
+//--------------------
+
+// stdRateBucket counts the increments during one part
+// of the sliding window.
+type stdRateBucket struct {
+	index int64
+	count int64
+}
+
+// stdRate counts the increments of a stay-set variable
+// in buckets covering the sliding window.
+type stdRate struct {
+	window  time.Duration
+	buckets [rateBuckets]stdRateBucket
+}
+
+// width returns the duration covered by one bucket.
+func (r *stdRate) width() int64 {
+	if w := int64(r.window) / rateBuckets; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// add adds increments at the given time to its bucket.
+func (r *stdRate) add(now time.Time, window time.Duration, n int64) {
+	if r.window != window {
+		*r = stdRate{window: window}
+	}
+	index := now.UnixNano() / r.width()
+	bucket := &r.buckets[index%rateBuckets]
+	if bucket.index != index {
+		bucket.index = index
+		bucket.count = 0
+	}
+	bucket.count += n
+}
+
+// perSecond returns the increments per second during the
+// window ending at the given time.
+func (r *stdRate) perSecond(now time.Time, window time.Duration) float64 {
+	if r.window != window {
+		return 0
+	}
+	index := now.UnixNano() / r.width()
+	total := int64(0)
+	for _, bucket := range r.buckets {
+		if bucket.index > index-rateBuckets && bucket.index <= index {
+			total += bucket.count
+		}
+	}
+	return float64(total) / window.Seconds()
+}
+
 //--------------------
 // DYNAMIC STATUS RETRIEVER
 //--------------------
@@ -243,6 +368,45 @@ func (dsv *stdDynamicStatusValue) String() string {
 	return fmt.Sprintf("Dynamic Status Value %q (value = %q)", dsv.id, dsv.value)
 }
 
+//--------------------
+// GAUGE
+//--------------------
+
+// stdGaugeRegistration allows the registration of a gauge function.
+type stdGaugeRegistration struct {
+	id string
+	gf GaugeFunc
+}
+
+// stdGauge implements the Gauge interface.
+type stdGauge struct {
+	id    string
+	value float64
+}
+
+// ID implements the Gauge interface.
+func (g *stdGauge) ID() string { return g.id }
+
+// Value implements the Gauge interface.
+func (g *stdGauge) Value() float64 { return g.value }
+
+// String implements the Stringer interface.
+func (g *stdGauge) String() string {
+	return fmt.Sprintf("Gauge %q (value = %v)", g.id, g.value)
+}
+
+// readGauge calls the gauge function outside of the backend. So
+// a panic is isolated, it is logged and returned as error.
+func readGauge(id string, gf GaugeFunc) (value float64, err error) {
+	defer func() {
+		if reason := recover(); reason != nil {
+			logger.Errorf("gauge %q panicked: %v", id, reason)
+			err = errors.New(ErrGaugePanicked, errorMessages, id, reason)
+		}
+	}()
+	return gf(), nil
+}
+
 //--------------------
 // BACKEND
 //--------------------
@@ -255,10 +419,14 @@ type stdBackend struct {
 	etmData                map[string]*stdMeasuringPoint
 	ssvData                map[string]*stdStaySetVariable
 	dsrData                map[string]DynamicStatusRetriever
+	gaugeData              map[string]GaugeFunc
 	measuringC             chan *stdMeasuring
 	ssvChangeC             chan *stdSSVChange
 	retrieverRegistrationC chan *stdRetrieverRegistration
 	commandC               chan *command
+	rnd                    *rand.Rand
+	rateWindow             time.Duration
+	rates                  map[string]*stdRate
 	backend                loop.Loop
 }
 
@@ -269,6 +437,8 @@ func NewStandardBackend() Backend {
 		ssvChangeC:             make(chan *stdSSVChange, 1024),
 		retrieverRegistrationC: make(chan *stdRetrieverRegistration, 16),
 		commandC:               make(chan *command),
+		rnd:                    rand.New(rand.NewSource(time.Now().UnixNano())),
+		rateWindow:             defaultRateWindow,
 	}
 	m.backend = loop.GoRecoverable(m.backendLoop, m.checkRecovering, "monitoring backend")
 	return m
@@ -350,6 +520,24 @@ func (b *stdBackend) StaySetVariablesDo(f func(StaySetVariable)) error {
 	return nil
 }
 
+// RateVariable implements the RateBackend interface.
+func (b *stdBackend) RateVariable(id string) (float64, error) {
+	resp, err := b.command(cmdStaySetVariableRate, id)
+	if err != nil {
+		return 0, err
+	}
+	return resp.(float64), nil
+}
+
+// SetRateWindow implements the RateBackend interface.
+func (b *stdBackend) SetRateWindow(window time.Duration) time.Duration {
+	resp, err := b.command(cmdSetRateWindow, window)
+	if err != nil {
+		return 0
+	}
+	return resp.(time.Duration)
+}
+
 // Register implements the MonitorBackend interface.
 func (b *stdBackend) Register(id string, rf DynamicStatusRetriever) {
 	if b.dsrFilter != nil && !b.dsrFilter(id) {
@@ -380,6 +568,51 @@ func (b *stdBackend) DynamicStatusValuesDo(f func(DynamicStatusValue)) error {
 	return nil
 }
 
+// RegisterGauge implements the GaugeBackend interface.
+func (b *stdBackend) RegisterGauge(id string, gf GaugeFunc) {
+	b.command(cmdGaugeRegister, &stdGaugeRegistration{id, gf})
+}
+
+// ReadGauge implements the GaugeBackend interface.
+func (b *stdBackend) ReadGauge(id string) (float64, error) {
+	resp, err := b.command(cmdGaugeRead, id)
+	if err != nil {
+		return 0, err
+	}
+	return readGauge(id, resp.(GaugeFunc))
+}
+
+// GaugesDo implements the GaugeBackend interface.
+func (b *stdBackend) GaugesDo(f func(Gauge)) error {
+	resp, err := b.command(cmdGaugesReadAll, nil)
+	if err != nil {
+		return err
+	}
+	registrations := resp.([]*stdGaugeRegistration)
+	gs := Gauges{}
+	for _, registration := range registrations {
+		value, err := readGauge(registration.id, registration.gf)
+		if err != nil {
+			continue
+		}
+		gs = append(gs, &stdGauge{registration.id, value})
+	}
+	sort.Sort(gs)
+	for _, g := range gs {
+		f(g)
+	}
+	return nil
+}
+
+// Snapshot implements the SnapshotBackend interface.
+func (b *stdBackend) Snapshot() (*RegistrySnapshot, error) {
+	resp, err := b.command(cmdSnapshot, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*RegistrySnapshot), nil
+}
+
 // SetMeasuringsFilter implements the MonitorBackend interface.
 func (b *stdBackend) SetMeasuringsFilter(f IDFilter) IDFilter {
 	old := b.etmFilter
@@ -410,6 +643,24 @@ func (b *stdBackend) Reset() error {
 	return nil
 }
 
+// ResetMeasuring implements the ResettingBackend interface.
+func (b *stdBackend) ResetMeasuring(id string) error {
+	_, err := b.command(cmdResetMeasuring, id)
+	return err
+}
+
+// ResetVariable implements the ResettingBackend interface.
+func (b *stdBackend) ResetVariable(id string) error {
+	_, err := b.command(cmdResetVariable, id)
+	return err
+}
+
+// ResetAll implements the ResettingBackend interface.
+func (b *stdBackend) ResetAll() error {
+	_, err := b.command(cmdResetAll, nil)
+	return err
+}
+
 // Stop implements the MonitorBackend interface.
 func (b *stdBackend) Stop() {
 	b.backend.Stop()
@@ -434,6 +685,8 @@ func (b *stdBackend) init() {
 	b.etmData = make(map[string]*stdMeasuringPoint)
 	b.ssvData = make(map[string]*stdStaySetVariable)
 	b.dsrData = make(map[string]DynamicStatusRetriever)
+	b.gaugeData = make(map[string]GaugeFunc)
+	b.rates = make(map[string]*stdRate)
 }
 
 // backendLoop runs the system monitor.
@@ -447,18 +700,10 @@ func (b *stdBackend) backendLoop(l loop.Loop) error {
 			return nil
 		case measuring := <-b.measuringC:
 			// Received a new measuring.
-			if mp, ok := b.etmData[measuring.id]; ok {
-				mp.update(measuring)
-			} else {
-				b.etmData[measuring.id] = newStdMeasuringPoint(measuring)
-			}
+			b.processMeasuring(measuring)
 		case ssvChange := <-b.ssvChangeC:
 			// Received a new change.
-			if ssv, ok := b.ssvData[ssvChange.id]; ok {
-				ssv.update(ssvChange)
-			} else {
-				b.ssvData[ssvChange.id] = newStdStaySetVariable(ssvChange)
-			}
+			b.processSSVChange(ssvChange)
 		case registration := <-b.retrieverRegistrationC:
 			// Received a new retriever for registration.
 			b.dsrData[registration.id] = registration.dsr
@@ -469,21 +714,76 @@ func (b *stdBackend) backendLoop(l loop.Loop) error {
 	}
 }
 
+// processMeasuring adds a measuring to its measuring point.
+func (b *stdBackend) processMeasuring(measuring *stdMeasuring) {
+	if mp, ok := b.etmData[measuring.id]; ok {
+		mp.update(measuring, b.rnd)
+	} else {
+		b.etmData[measuring.id] = newStdMeasuringPoint(measuring)
+	}
+}
+
+// processSSVChange applies a change to its stay-set variable.
+func (b *stdBackend) processSSVChange(ssvChange *stdSSVChange) {
+	if ssv, ok := b.ssvData[ssvChange.id]; ok {
+		ssv.update(ssvChange)
+	} else {
+		b.ssvData[ssvChange.id] = newStdStaySetVariable(ssvChange)
+	}
+	if !ssvChange.absolute && ssvChange.variable > 0 {
+		rate, ok := b.rates[ssvChange.id]
+		if !ok {
+			rate = &stdRate{window: b.rateWindow}
+			b.rates[ssvChange.id] = rate
+		}
+		rate.add(time.Now(), b.rateWindow, ssvChange.variable)
+	}
+}
+
+// processPending processes the measurings and changes already
+// queued, so that a following reset also covers them.
+func (b *stdBackend) processPending() {
+	for n := len(b.measuringC); n > 0; n-- {
+		b.processMeasuring(<-b.measuringC)
+	}
+	for n := len(b.ssvChangeC); n > 0; n-- {
+		b.processSSVChange(<-b.ssvChangeC)
+	}
+}
+
 // processCommand handles the received commands of the monitor.
 func (b *stdBackend) processCommand(cmd *command) {
 	defer cmd.close()
 	switch cmd.opCode {
 	case cmdReset:
 		// Reset monitoring.
+		b.processPending()
 		b.init()
 		cmd.ok()
+	case cmdResetMeasuring:
+		// Reset just one measuring point.
+		b.processPending()
+		delete(b.etmData, cmd.args.(string))
+		cmd.ok()
+	case cmdResetVariable:
+		// Reset just one stay-set variable.
+		b.processPending()
+		delete(b.ssvData, cmd.args.(string))
+		delete(b.rates, cmd.args.(string))
+		cmd.ok()
+	case cmdResetAll:
+		// Reset measuring points and stay-set variables.
+		b.processPending()
+		b.etmData = make(map[string]*stdMeasuringPoint)
+		b.ssvData = make(map[string]*stdStaySetVariable)
+		b.rates = make(map[string]*stdRate)
+		cmd.ok()
 	case cmdMeasuringPointRead:
 		// Read just one measuring point.
 		id := cmd.args.(string)
 		if mp, ok := b.etmData[id]; ok {
 			// Measuring point found.
-			clone := *mp
-			cmd.respond(&clone)
+			cmd.respond(mp.clone())
 		} else {
 			// Measuring point does not exist.
 			cmd.respond(errors.New(ErrMeasuringPointNotExists, errorMessages, id))
@@ -492,8 +792,7 @@ func (b *stdBackend) processCommand(cmd *command) {
 		// Read all measuring points.
 		resp := MeasuringPoints{}
 		for _, mp := range b.etmData {
-			clone := *mp
-			resp = append(resp, &clone)
+			resp = append(resp, mp.clone())
 		}
 		sort.Sort(resp)
 		cmd.respond(resp)
@@ -517,6 +816,26 @@ func (b *stdBackend) processCommand(cmd *command) {
 		}
 		sort.Sort(resp)
 		cmd.respond(resp)
+	case cmdStaySetVariableRate:
+		// Read the rate of one stay-set variable.
+		id := cmd.args.(string)
+		b.processPending()
+		if _, ok := b.ssvData[id]; !ok {
+			cmd.respond(errors.New(ErrStaySetVariableNotExists, errorMessages, id))
+			break
+		}
+		rate := 0.0
+		if r, ok := b.rates[id]; ok {
+			rate = r.perSecond(time.Now(), b.rateWindow)
+		}
+		cmd.respond(rate)
+	case cmdSetRateWindow:
+		// Set the window of the rates.
+		old := b.rateWindow
+		if window := cmd.args.(time.Duration); window > 0 {
+			b.rateWindow = window
+		}
+		cmd.respond(old)
 	case cmdDynamicStatusRetrieverRead:
 		// Read just one dynamic status value.
 		id := cmd.args.(string)
@@ -545,6 +864,44 @@ func (b *stdBackend) processCommand(cmd *command) {
 		}
 		sort.Sort(resp)
 		cmd.respond(resp)
+	case cmdGaugeRegister:
+		// Register a gauge.
+		registration := cmd.args.(*stdGaugeRegistration)
+		b.gaugeData[registration.id] = registration.gf
+		cmd.ok()
+	case cmdGaugeRead:
+		// Read the function of one gauge.
+		id := cmd.args.(string)
+		if gf, ok := b.gaugeData[id]; ok {
+			cmd.respond(gf)
+		} else {
+			cmd.respond(errors.New(ErrGaugeNotExists, errorMessages, id))
+		}
+	case cmdGaugesReadAll:
+		// Read the functions of all gauges.
+		resp := []*stdGaugeRegistration{}
+		for id, gf := range b.gaugeData {
+			resp = append(resp, &stdGaugeRegistration{id, gf})
+		}
+		cmd.respond(resp)
+	case cmdSnapshot:
+		// Copy measuring points and stay-set variables.
+		b.processPending()
+		resp := &RegistrySnapshot{
+			Time:             time.Now(),
+			MeasuringPoints:  MeasuringPoints{},
+			StaySetVariables: StaySetVariables{},
+		}
+		for _, mp := range b.etmData {
+			resp.MeasuringPoints = append(resp.MeasuringPoints, mp.clone())
+		}
+		for _, ssv := range b.ssvData {
+			clone := *ssv
+			resp.StaySetVariables = append(resp.StaySetVariables, &clone)
+		}
+		sort.Sort(resp.MeasuringPoints)
+		sort.Sort(resp.StaySetVariables)
+		cmd.respond(resp)
 	}
 }
 
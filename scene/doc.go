@@ -28,6 +28,20 @@
 //    foo, err := scn.Fetch("foo")
 //    foo, err := scn.Dispose("foo")
 //
+// Typed variants like FetchString(), FetchInt(), FetchBool(), and
+// FetchDuration() save the type assertions. A prop of a different type
+// is returned as error, it can be distinguished from a missing prop with
+// IsPropWrongTypeError().
+//
+//    timeout, err := scn.FetchDuration("timeout")
+//
+// Snapshot() atomically returns a shallow copy of all props, e.g. for
+// logging the contents of a scene in case of an error. Restore() stores
+// such a copy in another scene.
+//
+//    props, err := scn.Snapshot()
+//    err = subScn.Restore(props)
+//
 // It's also possible to cleanup if a prop is disposed or the whole
 // scene is stopped or aborted.
 //
@@ -48,6 +62,12 @@
 //
 //    scn.Abort(myError)
 //
+// Props stored with StoreWithTTL() are removed after their time-to-live,
+// even without any further access. Fetching an expired prop returns a
+// prop not found error, the key can be used again.
+//
+//    err := scn.StoreWithTTL("token", myToken, 10 * time.Minute)
+//
 // Another functionality of the scene is the signaling of a topic. So
 // multiple goroutines can wait for a signal with a topic, all will be
 // notified after the topic has been signaled. Additionally they can wait
@@ -68,6 +88,26 @@
 // will be used as flag topic and a waiter knows that the information is
 // available.
 //
+// Signal() and WaitSignal() work like flags. But a signal can be latched
+// only for a grace period set with SetSignalGrace(). Actors that start
+// waiting after it is over have to wait for the next signal. With the
+// default grace of zero a signal is latched until Unflag().
+//
+//    err := scn.SetSignalGrace(time.Second)
+//    err = scn.Signal("foo")
+//    ...
+//    err = scn.WaitSignal("foo", 5 * time.Second)
+//
+// Instead of a flag the waiting can also be directly for a prop. WaitFor()
+// blocks until the key is stored or the timeout happened and returns the
+// prop. All waiters for the same key are unblocked.
+//
+//    go func() {
+//        foo, err := scn.WaitFor("foo", 5 * time.Second)
+//        ...
+//    }()
+//    err := scn.Store("foo", myFoo)
+//
 // A scene knows two different timeouts. The first is the time of inactivity,
 // the second is the absolute maximum time of a scene.
 //
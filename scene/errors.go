@@ -26,15 +26,19 @@ const (
 	ErrPropNotFound
 	ErrCleanupFailed
 	ErrWaitedTooLong
+	ErrWaitedTooLongForProp
+	ErrPropWrongType
 )
 
 var errorMessages = errors.Messages{
-	ErrSceneEnded:       "scene already ended",
-	ErrTimeout:          "scene %s timeout reached at %v",
-	ErrPropAlreadyExist: "property %q already exist",
-	ErrPropNotFound:     "property %q does not exist",
-	ErrCleanupFailed:    "cleanup of property %q failed",
-	ErrWaitedTooLong:    "waiting for signal %q timed out",
+	ErrSceneEnded:           "scene already ended",
+	ErrTimeout:              "scene %s timeout reached at %v",
+	ErrPropAlreadyExist:     "property %q already exist",
+	ErrPropNotFound:         "property %q does not exist",
+	ErrCleanupFailed:        "cleanup of property %q failed",
+	ErrWaitedTooLong:        "waiting for signal %q timed out",
+	ErrWaitedTooLongForProp: "waiting for property %q timed out",
+	ErrPropWrongType:        "property %q has type %T instead of %s",
 }
 
 //--------------------
@@ -77,4 +81,16 @@ func IsWaitedTooLongError(err error) bool {
 	return errors.IsError(err, ErrWaitedTooLong)
 }
 
+// IsWaitedTooLongForPropError returns true, if the error signals a
+// timeout when waiting for a prop.
+func IsWaitedTooLongForPropError(err error) bool {
+	return errors.IsError(err, ErrWaitedTooLongForProp)
+}
+
+// IsPropWrongTypeError returns true, if the error signals a
+// prop not having the requested type.
+func IsPropWrongTypeError(err error) bool {
+	return errors.IsError(err, ErrPropWrongType)
+}
+
 // EOF
@@ -0,0 +1,20 @@
+// Tideland Go Library - Scene - Export Test
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package scene
+
+//--------------------
+// EXPORTED FUNCTIONS
+//--------------------
+
+// PropWaiters returns the number of waiters for the key. It must
+// only be called while no other command is processed.
+func PropWaiters(scn Scene, key string) int {
+	return len(scn.(*scene).propWaiters[key])
+}
+
+// EOF
@@ -16,6 +16,7 @@ import (
 
 	"github.com/tideland/golib/errors"
 	"github.com/tideland/golib/identifier"
+	"github.com/tideland/golib/logger"
 	"github.com/tideland/golib/loop"
 )
 
@@ -38,21 +39,35 @@ type box struct {
 	key     string
 	prop    interface{}
 	cleanup CleanupFunc
+	expires time.Time
+}
+
+// expired checks if the box has a time-to-live which
+// is over at the passed time.
+func (b *box) expired(now time.Time) bool {
+	return !b.expires.IsZero() && !now.Before(b.expires)
 }
 
 // signaling contains a topic and a signal channel.
 type signaling struct {
 	topic      string
 	signalChan chan struct{}
+	grace      time.Duration
 }
 
 const (
 	storeProp = iota
 	fetchProp
 	disposeProp
+	snapshotProps
+	restoreProps
 	flag
 	unflag
+	signal
+	setSignalGrace
 	wait
+	waitProp
+	unwaitProp
 )
 
 // envelope contains information transfered between client and scene.
@@ -60,6 +75,8 @@ type envelope struct {
 	kind      int
 	box       *box
 	signaling *signaling
+	propChan  chan interface{}
+	props     map[string]interface{}
 	err       error
 	respChan  chan *envelope
 }
@@ -101,12 +118,42 @@ type Scene interface {
 	// The storing is signaled with the key as topic.
 	StoreCleanAndFlag(key string, prop interface{}, cleanup CleanupFunc) error
 
+	// StoreWithTTL stores a prop with a given key which is removed
+	// after the time-to-live. The key must not exist. A time-to-live
+	// not greater than zero lets the prop live as long as the scene.
+	StoreWithTTL(key string, prop interface{}, ttl time.Duration) error
+
 	// Fetch retrieves a prop.
 	Fetch(key string) (interface{}, error)
 
+	// FetchString retrieves a prop which has to be a string.
+	FetchString(key string) (string, error)
+
+	// FetchInt retrieves a prop which has to be an int.
+	FetchInt(key string) (int, error)
+
+	// FetchBool retrieves a prop which has to be a bool.
+	FetchBool(key string) (bool, error)
+
+	// FetchDuration retrieves a prop which has to be a time.Duration.
+	FetchDuration(key string) (time.Duration, error)
+
+	// WaitFor waits until a prop with the given key is stored or
+	// the timeout happened and returns it. A timeout of zero lets
+	// it wait until the key is stored or the scene ends.
+	WaitFor(key string, timeout time.Duration) (interface{}, error)
+
 	// Dispose retrieves a prop and deletes it from the store.
 	Dispose(key string) (interface{}, error)
 
+	// Snapshot returns a shallow copy of all props not expired.
+	Snapshot() (map[string]interface{}, error)
+
+	// Restore stores all passed props, e.g. from a snapshot of
+	// another scene. None of the keys must exist, otherwise
+	// no prop is stored.
+	Restore(props map[string]interface{}) error
+
 	// Flag allows to signal a topic to interested actors.
 	Flag(topic string) error
 
@@ -116,6 +163,21 @@ type Scene interface {
 	// WaitFlag waits until the passed topic has been signaled.
 	WaitFlag(topic string) error
 
+	// Signal notifies all actors waiting for the topic. Like a flag
+	// it is latched for actors waiting later, but only for the
+	// grace period set with SetSignalGrace().
+	Signal(topic string) error
+
+	// SetSignalGrace sets the period a signal is latched for later
+	// waiters. A period not greater than zero, the default, latches
+	// signals until they are dropped with Unflag().
+	SetSignalGrace(grace time.Duration) error
+
+	// WaitSignal waits until the passed topic has been signaled
+	// or the timeout happened. A timeout of zero lets it wait
+	// until the signal or the end of the scene.
+	WaitSignal(topic string, timeout time.Duration) error
+
 	// WaitFlagAndFetch waits until the passed topic has been signaled.
 	// A prop stored at the topic as key is fetched.
 	WaitFlagAndFetch(topic string) (interface{}, error)
@@ -134,7 +196,11 @@ type scene struct {
 	id          identifier.UUID
 	props       map[string]*box
 	flags       map[string]bool
+	flagExpires map[string]time.Time
+	signalGrace time.Duration
 	signalings  map[string][]chan struct{}
+	propWaiters map[string][]chan interface{}
+	nextSweep   time.Time
 	inactivity  time.Duration
 	absolute    time.Duration
 	commandChan chan *envelope
@@ -153,7 +219,9 @@ func StartLimited(inactivity, absolute time.Duration) Scene {
 		id:          identifier.NewUUID(),
 		props:       make(map[string]*box),
 		flags:       make(map[string]bool),
+		flagExpires: make(map[string]time.Time),
 		signalings:  make(map[string][]chan struct{}),
+		propWaiters: make(map[string][]chan interface{}),
 		inactivity:  inactivity,
 		absolute:    absolute,
 		commandChan: make(chan *envelope, 1),
@@ -225,6 +293,23 @@ func (s *scene) StoreCleanAndFlag(key string, prop interface{}, cleanup CleanupF
 	return s.Flag(key)
 }
 
+// StoreWithTTL is specified on the Scene interface.
+func (s *scene) StoreWithTTL(key string, prop interface{}, ttl time.Duration) error {
+	command := &envelope{
+		kind: storeProp,
+		box: &box{
+			key:  key,
+			prop: prop,
+		},
+		respChan: make(chan *envelope, 1),
+	}
+	if ttl > 0 {
+		command.box.expires = time.Now().Add(ttl)
+	}
+	_, err := s.command(command)
+	return err
+}
+
 // Fetch is specified on the Scene interface.
 func (s *scene) Fetch(key string) (interface{}, error) {
 	command := &envelope{
@@ -241,6 +326,103 @@ func (s *scene) Fetch(key string) (interface{}, error) {
 	return resp.box.prop, nil
 }
 
+// FetchString is specified on the Scene interface.
+func (s *scene) FetchString(key string) (string, error) {
+	prop, err := s.Fetch(key)
+	if err != nil {
+		return "", err
+	}
+	value, ok := prop.(string)
+	if !ok {
+		return "", errors.New(ErrPropWrongType, errorMessages, key, prop, "string")
+	}
+	return value, nil
+}
+
+// FetchInt is specified on the Scene interface.
+func (s *scene) FetchInt(key string) (int, error) {
+	prop, err := s.Fetch(key)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := prop.(int)
+	if !ok {
+		return 0, errors.New(ErrPropWrongType, errorMessages, key, prop, "int")
+	}
+	return value, nil
+}
+
+// FetchBool is specified on the Scene interface.
+func (s *scene) FetchBool(key string) (bool, error) {
+	prop, err := s.Fetch(key)
+	if err != nil {
+		return false, err
+	}
+	value, ok := prop.(bool)
+	if !ok {
+		return false, errors.New(ErrPropWrongType, errorMessages, key, prop, "bool")
+	}
+	return value, nil
+}
+
+// FetchDuration is specified on the Scene interface.
+func (s *scene) FetchDuration(key string) (time.Duration, error) {
+	prop, err := s.Fetch(key)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := prop.(time.Duration)
+	if !ok {
+		return 0, errors.New(ErrPropWrongType, errorMessages, key, prop, "time.Duration")
+	}
+	return value, nil
+}
+
+// WaitFor is specified on the Scene interface.
+func (s *scene) WaitFor(key string, timeout time.Duration) (interface{}, error) {
+	// Add prop channel.
+	command := &envelope{
+		kind: waitProp,
+		box: &box{
+			key: key,
+		},
+		propChan: make(chan interface{}, 1),
+		respChan: make(chan *envelope, 1),
+	}
+	_, err := s.command(command)
+	if err != nil {
+		return nil, err
+	}
+	// Wait for prop.
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
+	select {
+	case <-s.backend.IsStopping():
+		err = s.Wait()
+		if err == nil {
+			err = errors.New(ErrSceneEnded, errorMessages)
+		}
+		return nil, err
+	case prop := <-command.propChan:
+		return prop, nil
+	case <-timeoutChan:
+		// Remove prop channel.
+		command.kind = unwaitProp
+		if _, err = s.command(command); err != nil {
+			return nil, err
+		}
+		select {
+		case prop := <-command.propChan:
+			// Stored in the meantime.
+			return prop, nil
+		default:
+			return nil, errors.New(ErrWaitedTooLongForProp, errorMessages, key)
+		}
+	}
+}
+
 // Dispose is specified on the Scene interface.
 func (s *scene) Dispose(key string) (interface{}, error) {
 	command := &envelope{
@@ -257,6 +439,30 @@ func (s *scene) Dispose(key string) (interface{}, error) {
 	return resp.box.prop, nil
 }
 
+// Snapshot is specified on the Scene interface.
+func (s *scene) Snapshot() (map[string]interface{}, error) {
+	command := &envelope{
+		kind:     snapshotProps,
+		respChan: make(chan *envelope, 1),
+	}
+	resp, err := s.command(command)
+	if err != nil {
+		return nil, err
+	}
+	return resp.props, nil
+}
+
+// Restore is specified on the Scene interface.
+func (s *scene) Restore(props map[string]interface{}) error {
+	command := &envelope{
+		kind:     restoreProps,
+		props:    props,
+		respChan: make(chan *envelope, 1),
+	}
+	_, err := s.command(command)
+	return err
+}
+
 // Flag is specified on the Scene interface.
 func (s *scene) Flag(topic string) error {
 	command := &envelope{
@@ -288,6 +494,37 @@ func (s *scene) WaitFlag(topic string) error {
 	return s.WaitFlagLimited(topic, 0)
 }
 
+// Signal is specified on the Scene interface.
+func (s *scene) Signal(topic string) error {
+	command := &envelope{
+		kind: signal,
+		signaling: &signaling{
+			topic: topic,
+		},
+		respChan: make(chan *envelope, 1),
+	}
+	_, err := s.command(command)
+	return err
+}
+
+// SetSignalGrace is specified on the Scene interface.
+func (s *scene) SetSignalGrace(grace time.Duration) error {
+	command := &envelope{
+		kind: setSignalGrace,
+		signaling: &signaling{
+			grace: grace,
+		},
+		respChan: make(chan *envelope, 1),
+	}
+	_, err := s.command(command)
+	return err
+}
+
+// WaitSignal is specified on the Scene interface.
+func (s *scene) WaitSignal(topic string, timeout time.Duration) error {
+	return s.WaitFlagLimited(topic, timeout)
+}
+
 // WaitFlagAndFetch is specified on the Scene interface.
 func (s *scene) WaitFlagAndFetch(topic string) (interface{}, error) {
 	err := s.WaitFlag(topic)
@@ -382,10 +619,14 @@ func (s *scene) backendLoop(l loop.Loop) (err error) {
 	if s.absolute > 0 {
 		clapperboard = time.After(s.absolute)
 	}
+	if s.inactivity > 0 {
+		watchdog = time.After(s.inactivity)
+	}
 	// Run loop.
 	for {
-		if s.inactivity > 0 {
-			watchdog = time.After(s.inactivity)
+		var sweeper <-chan time.Time
+		if !s.nextSweep.IsZero() {
+			sweeper = time.After(time.Until(s.nextSweep))
 		}
 		select {
 		case <-l.ShallStop():
@@ -394,8 +635,13 @@ func (s *scene) backendLoop(l loop.Loop) (err error) {
 			return errors.New(ErrTimeout, errorMessages, "inactivity", timeout)
 		case timeout := <-clapperboard:
 			return errors.New(ErrTimeout, errorMessages, "absolute", timeout)
+		case now := <-sweeper:
+			s.sweep(now)
 		case command := <-s.commandChan:
 			s.processCommand(command)
+			if s.inactivity > 0 {
+				watchdog = time.After(s.inactivity)
+			}
 		}
 	}
 }
@@ -405,15 +651,15 @@ func (s *scene) processCommand(command *envelope) {
 	switch command.kind {
 	case storeProp:
 		// Add a new prop.
-		_, ok := s.props[command.box.key]
+		_, ok := s.lookup(command.box.key)
 		if ok {
 			command.err = errors.New(ErrPropAlreadyExist, errorMessages, command.box.key)
 		} else {
-			s.props[command.box.key] = command.box
+			s.store(command.box)
 		}
 	case fetchProp:
 		// Retrieve a prop.
-		box, ok := s.props[command.box.key]
+		box, ok := s.lookup(command.box.key)
 		if !ok {
 			command.err = errors.New(ErrPropNotFound, errorMessages, command.box.key)
 		} else {
@@ -421,7 +667,7 @@ func (s *scene) processCommand(command *envelope) {
 		}
 	case disposeProp:
 		// Remove a prop.
-		box, ok := s.props[command.box.key]
+		box, ok := s.lookup(command.box.key)
 		if !ok {
 			command.err = errors.New(ErrPropNotFound, errorMessages, command.box.key)
 		} else {
@@ -434,29 +680,82 @@ func (s *scene) processCommand(command *envelope) {
 				}
 			}
 		}
-	case flag:
-		// Signal a topic.
-		s.flags[command.signaling.topic] = true
-		// Notify subscribers.
-		subscribers, ok := s.signalings[command.signaling.topic]
-		if ok {
-			delete(s.signalings, command.signaling.topic)
-			for _, subscriber := range subscribers {
-				subscriber <- struct{}{}
+	case snapshotProps:
+		// Copy all props.
+		now := time.Now()
+		command.props = make(map[string]interface{}, len(s.props))
+		for key, box := range s.props {
+			if !box.expired(now) {
+				command.props[key] = box.prop
+			}
+		}
+	case restoreProps:
+		// Add all passed props if none exists.
+		for key := range command.props {
+			if _, ok := s.lookup(key); ok {
+				command.err = errors.New(ErrPropAlreadyExist, errorMessages, key)
+				break
 			}
 		}
+		if command.err == nil {
+			for key, prop := range command.props {
+				s.store(&box{
+					key:  key,
+					prop: prop,
+				})
+			}
+		}
+	case flag:
+		// Signal a topic.
+		s.raise(command.signaling.topic)
+		delete(s.flagExpires, command.signaling.topic)
 	case unflag:
 		// Drop a topic.
 		delete(s.flags, command.signaling.topic)
+		delete(s.flagExpires, command.signaling.topic)
+	case signal:
+		// Signal a topic only latched for the grace period.
+		s.raise(command.signaling.topic)
+		if s.signalGrace > 0 {
+			s.flagExpires[command.signaling.topic] = time.Now().Add(s.signalGrace)
+		} else {
+			delete(s.flagExpires, command.signaling.topic)
+		}
+	case setSignalGrace:
+		// Change the grace period of future signals.
+		s.signalGrace = command.signaling.grace
 	case wait:
 		// Add a waiter for a topic.
-		active := s.flags[command.signaling.topic]
+		active := s.flagged(command.signaling.topic)
 		if active {
 			command.signaling.signalChan <- struct{}{}
 		} else {
 			waiters := s.signalings[command.signaling.topic]
 			s.signalings[command.signaling.topic] = append(waiters, command.signaling.signalChan)
 		}
+	case waitProp:
+		// Add a waiter for a prop.
+		box, ok := s.lookup(command.box.key)
+		if ok {
+			command.propChan <- box.prop
+		} else {
+			waiters := s.propWaiters[command.box.key]
+			s.propWaiters[command.box.key] = append(waiters, command.propChan)
+		}
+	case unwaitProp:
+		// Remove a waiter for a prop after its timeout.
+		waiters := s.propWaiters[command.box.key]
+		for i, waiter := range waiters {
+			if waiter == command.propChan {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(s.propWaiters, command.box.key)
+		} else {
+			s.propWaiters[command.box.key] = waiters
+		}
 	default:
 		panic("illegal command")
 	}
@@ -464,6 +763,88 @@ func (s *scene) processCommand(command *envelope) {
 	command.respChan <- command
 }
 
+// store adds a box and notifies the waiters for its key.
+func (s *scene) store(box *box) {
+	s.props[box.key] = box
+	if !box.expires.IsZero() && (s.nextSweep.IsZero() || box.expires.Before(s.nextSweep)) {
+		s.nextSweep = box.expires
+	}
+	waiters, ok := s.propWaiters[box.key]
+	if ok {
+		delete(s.propWaiters, box.key)
+		for _, waiter := range waiters {
+			waiter <- box.prop
+		}
+	}
+}
+
+// raise flags a topic and notifies its subscribers.
+func (s *scene) raise(topic string) {
+	s.flags[topic] = true
+	subscribers, ok := s.signalings[topic]
+	if ok {
+		delete(s.signalings, topic)
+		for _, subscriber := range subscribers {
+			subscriber <- struct{}{}
+		}
+	}
+}
+
+// flagged checks if a topic is flagged. Signals with a grace
+// period which is over are dropped.
+func (s *scene) flagged(topic string) bool {
+	if !s.flags[topic] {
+		return false
+	}
+	expires, ok := s.flagExpires[topic]
+	if ok && !time.Now().Before(expires) {
+		delete(s.flags, topic)
+		delete(s.flagExpires, topic)
+		return false
+	}
+	return true
+}
+
+// lookup returns the box for a key. If its time-to-live is
+// over it is expired and not returned.
+func (s *scene) lookup(key string) (*box, bool) {
+	box, ok := s.props[key]
+	if !ok {
+		return nil, false
+	}
+	if box.expired(time.Now()) {
+		s.expire(box)
+		return nil, false
+	}
+	return box, true
+}
+
+// sweep expires all boxes with a time-to-live over at the
+// passed time and sets the time of the next sweep.
+func (s *scene) sweep(now time.Time) {
+	var next time.Time
+	for _, box := range s.props {
+		switch {
+		case box.expired(now):
+			s.expire(box)
+		case !box.expires.IsZero() && (next.IsZero() || box.expires.Before(next)):
+			next = box.expires
+		}
+	}
+	s.nextSweep = next
+}
+
+// expire removes a box and cleans it up. As there's
+// no caller to return an error to it is logged.
+func (s *scene) expire(box *box) {
+	delete(s.props, box.key)
+	if box.cleanup != nil {
+		if err := box.cleanup(box.key, box.prop); err != nil {
+			logger.Errorf("cleanup of expired property %q failed: %v", box.key, err)
+		}
+	}
+}
+
 // cleanupAllProps cleans all props.
 func (s *scene) cleanupAllProps() error {
 	for _, box := range s.props {
@@ -81,6 +81,141 @@ func TestAccessAfterStopping(t *testing.T) {
 	assert.Nil(foo)
 }
 
+// TestTypedFetch tests the fetching of props with a type.
+func TestTypedFetch(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scn := scene.Start()
+
+	assert.Nil(scn.Store("string", "foo"))
+	assert.Nil(scn.Store("int", 4711))
+	assert.Nil(scn.Store("bool", true))
+	assert.Nil(scn.Store("duration", 5*time.Second))
+
+	s, err := scn.FetchString("string")
+	assert.Nil(err)
+	assert.Equal(s, "foo")
+	i, err := scn.FetchInt("int")
+	assert.Nil(err)
+	assert.Equal(i, 4711)
+	b, err := scn.FetchBool("bool")
+	assert.Nil(err)
+	assert.Equal(b, true)
+	d, err := scn.FetchDuration("duration")
+	assert.Nil(err)
+	assert.Equal(d, 5*time.Second)
+
+	_, err = scn.FetchString("int")
+	assert.True(scene.IsPropWrongTypeError(err))
+	assert.ErrorMatch(err, `.* property "int" has type int instead of string`)
+	_, err = scn.FetchInt("string")
+	assert.True(scene.IsPropWrongTypeError(err))
+	_, err = scn.FetchBool("duration")
+	assert.True(scene.IsPropWrongTypeError(err))
+	_, err = scn.FetchDuration("int")
+	assert.True(scene.IsPropWrongTypeError(err))
+	assert.False(scene.IsPropNotFoundError(err))
+
+	_, err = scn.FetchString("missing")
+	assert.True(scene.IsPropNotFoundError(err))
+	assert.False(scene.IsPropWrongTypeError(err))
+
+	err = scn.Stop()
+	assert.Nil(err)
+}
+
+// TestSnapshotRestore tests taking a snapshot of one scene
+// and restoring it in another one.
+func TestSnapshotRestore(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scnA := scene.Start()
+	scnB := scene.Start()
+
+	assert.Nil(scnA.Store("foo", 4711))
+	assert.Nil(scnA.Store("bar", "baz"))
+	assert.Nil(scnA.StoreWithTTL("yadda", true, 50*time.Millisecond))
+
+	time.Sleep(100 * time.Millisecond)
+
+	props, err := scnA.Snapshot()
+	assert.Nil(err)
+	assert.Equal(props, map[string]interface{}{
+		"foo": 4711,
+		"bar": "baz",
+	})
+	props["foo"] = 1234
+	foo, err := scnA.Fetch("foo")
+	assert.Nil(err)
+	assert.Equal(foo, 4711)
+
+	go func() {
+		bar, err := scnB.WaitFor("bar", time.Second)
+		assert.Nil(err)
+		assert.Equal(bar, "baz")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	err = scnB.Restore(props)
+	assert.Nil(err)
+	foo, err = scnB.Fetch("foo")
+	assert.Nil(err)
+	assert.Equal(foo, 1234)
+	err = scnB.Restore(map[string]interface{}{
+		"yadda": false,
+		"foo":   0,
+	})
+	assert.True(scene.IsPropAlreadyExistError(err))
+	_, err = scnB.Fetch("yadda")
+	assert.True(scene.IsPropNotFoundError(err))
+
+	assert.Nil(scnA.Stop())
+	assert.Nil(scnB.Stop())
+	_, err = scnA.Snapshot()
+	assert.True(scene.IsSceneEndedError(err))
+}
+
+// TestStoreWithTTL tests storing props with a time-to-live.
+func TestStoreWithTTL(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scn := scene.Start()
+
+	err := scn.StoreWithTTL("foo", 4711, 50*time.Millisecond)
+	assert.Nil(err)
+	err = scn.StoreWithTTL("bar", "baz", 150*time.Millisecond)
+	assert.Nil(err)
+	err = scn.StoreWithTTL("yadda", true, 0)
+	assert.Nil(err)
+	err = scn.StoreWithTTL("foo", 1234, time.Second)
+	assert.True(scene.IsPropAlreadyExistError(err))
+	foo, err := scn.Fetch("foo")
+	assert.Nil(err)
+	assert.Equal(foo, 4711)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = scn.Fetch("foo")
+	assert.True(scene.IsPropNotFoundError(err))
+	bar, err := scn.Fetch("bar")
+	assert.Nil(err)
+	assert.Equal(bar, "baz")
+	err = scn.Store("foo", 1234)
+	assert.Nil(err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = scn.Dispose("bar")
+	assert.True(scene.IsPropNotFoundError(err))
+	foo, err = scn.Fetch("foo")
+	assert.Nil(err)
+	assert.Equal(foo, 1234)
+	yadda, err := scn.Fetch("yadda")
+	assert.Nil(err)
+	assert.Equal(yadda, true)
+
+	err = scn.Stop()
+	assert.Nil(err)
+}
+
 // TestCleanupNoError tests the cleanup of props with
 // no errors.
 func TestCleanupNoError(t *testing.T) {
@@ -394,4 +529,126 @@ func TestFlagUnflag(t *testing.T) {
 	assert.Nil(err)
 }
 
+// TestSignalBeforeWait tests the latching of signals
+// fired before the waiting.
+func TestSignalBeforeWait(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scn := scene.Start()
+
+	err := scn.Signal("foo")
+	assert.Nil(err)
+	err = scn.WaitSignal("foo", 50*time.Millisecond)
+	assert.Nil(err)
+
+	err = scn.SetSignalGrace(50 * time.Millisecond)
+	assert.Nil(err)
+	err = scn.Signal("bar")
+	assert.Nil(err)
+	err = scn.WaitSignal("bar", 50*time.Millisecond)
+	assert.Nil(err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = scn.WaitSignal("bar", 50*time.Millisecond)
+	assert.True(scene.IsWaitedTooLongError(err))
+	err = scn.WaitSignal("foo", 50*time.Millisecond)
+	assert.Nil(err)
+
+	err = scn.Stop()
+	assert.Nil(err)
+}
+
+// TestSignalAfterWait tests the signaling of waiters
+// already waiting.
+func TestSignalAfterWait(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scn := scene.Start()
+	errs := make(chan error, 2)
+
+	err := scn.SetSignalGrace(time.Millisecond)
+	assert.Nil(err)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- scn.WaitSignal("foo", time.Second)
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = scn.Signal("foo")
+	assert.Nil(err)
+	for i := 0; i < 2; i++ {
+		assert.Nil(<-errs)
+	}
+
+	err = scn.Stop()
+	assert.Nil(err)
+}
+
+// TestWaitFor tests the waiting for props to be stored.
+func TestWaitFor(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, false)
+	scn := scene.Start()
+	results := make(chan interface{}, 3)
+
+	for i := 0; i < 3; i++ {
+		go func() {
+			foo, err := scn.WaitFor("foo", time.Second)
+			assert.Nil(err)
+			results <- foo
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	err := scn.Store("foo", 4711)
+	assert.Nil(err)
+	for i := 0; i < 3; i++ {
+		select {
+		case foo := <-results:
+			assert.Equal(foo, 4711)
+		case <-time.After(time.Second):
+			assert.Fail("waiter not unblocked")
+		}
+	}
+
+	foo, err := scn.WaitFor("foo", 50*time.Millisecond)
+	assert.Nil(err)
+	assert.Equal(foo, 4711)
+	bar, err := scn.WaitFor("bar", 50*time.Millisecond)
+	assert.Nil(bar)
+	assert.True(scene.IsWaitedTooLongForPropError(err))
+	assert.Equal(scene.PropWaiters(scn, "bar"), 0)
+
+	// Timed out waiters are removed, the others stay.
+	go func() {
+		bar, err := scn.WaitFor("bar", time.Second)
+		assert.Nil(err)
+		results <- bar
+	}()
+	time.Sleep(20 * time.Millisecond)
+	bar, err = scn.WaitFor("bar", 50*time.Millisecond)
+	assert.Nil(bar)
+	assert.True(scene.IsWaitedTooLongForPropError(err))
+	assert.Equal(scene.PropWaiters(scn, "bar"), 1)
+	err = scn.Store("bar", "baz")
+	assert.Nil(err)
+	select {
+	case bar := <-results:
+		assert.Equal(bar, "baz")
+	case <-time.After(time.Second):
+		assert.Fail("waiter not unblocked")
+	}
+
+	go func() {
+		_, err := scn.WaitFor("baz", 0)
+		assert.True(scene.IsSceneEndedError(err))
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = scn.Stop()
+	assert.Nil(err)
+}
+
 // EOF
@@ -53,12 +53,23 @@ func ReadSML(reader io.Reader, builder Builder) error {
 	s := &mlReader{
 		reader:  bufio.NewReader(reader),
 		builder: builder,
-		index:   -1,
+		next:    position{1, 1},
 	}
 	if err := s.readPreliminary(); err != nil {
 		return err
 	}
-	return s.readTagNode()
+	return s.readTagNode(s.current)
+}
+
+// position is the line and column of a rune in the document.
+type position struct {
+	line   int
+	column int
+}
+
+// String implements the fmt.Stringer interface.
+func (p position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.line, p.column)
 }
 
 // mlReader is used by ReadSML to parse a SML document
@@ -66,7 +77,8 @@ func ReadSML(reader io.Reader, builder Builder) error {
 type mlReader struct {
 	reader  *bufio.Reader
 	builder Builder
-	index   int
+	current position
+	next    position
 }
 
 // readPreliminary reads the content before the first node.
@@ -77,21 +89,21 @@ func (mr *mlReader) readPreliminary() error {
 		case err != nil:
 			return err
 		case rc == rcEOF:
-			return errors.New(ErrReader, errorMessages, "unexpected end of file while reading preliminary")
+			return mr.errorf("unexpected end of file while reading preliminary")
 		case rc == rcOpen:
 			return nil
 		}
 	}
 }
 
-// readNode reads the next tag node.
-func (mr *mlReader) readTagNode() error {
+// readTagNode reads the next tag node starting at the position.
+func (mr *mlReader) readTagNode(start position) error {
 	tag, rc, err := mr.readTag()
 	if err != nil {
 		return err
 	}
 	if err = mr.builder.BeginTagNode(tag); err != nil {
-		return err
+		return mr.annotate(err, start)
 	}
 	// Read children.
 	if rc != rcClose {
@@ -99,7 +111,10 @@ func (mr *mlReader) readTagNode() error {
 			return err
 		}
 	}
-	return mr.builder.EndTagNode()
+	if err = mr.builder.EndTagNode(); err != nil {
+		return mr.annotate(err, mr.current)
+	}
+	return nil
 }
 
 // readTag reads the tag of a node. It als returns the class of the next rune.
@@ -111,14 +126,13 @@ func (mr *mlReader) readTag() (string, int, error) {
 		case err != nil:
 			return "", 0, err
 		case rc == rcEOF:
-			return "", 0, errors.New(ErrReader, errorMessages, "unexpected end of file while reading a tag")
+			return "", 0, mr.errorf("unexpected end of file while reading a tag")
 		case rc == rcTag:
 			buf.WriteRune(r)
 		case rc == rcSpace || rc == rcClose:
 			return buf.String(), rc, nil
 		default:
-			msg := fmt.Sprintf("invalid tag character at position %d", mr.index)
-			return "", 0, errors.New(ErrReader, errorMessages, msg)
+			return "", 0, mr.errorf("invalid tag character")
 		}
 	}
 }
@@ -131,7 +145,7 @@ func (mr *mlReader) readTagChildren() error {
 		case err != nil:
 			return err
 		case rc == rcEOF:
-			return errors.New(ErrReader, errorMessages, "unexpected end of file while reading children")
+			return mr.errorf("unexpected end of file while reading children")
 		case rc == rcClose:
 			return nil
 		case rc == rcOpen:
@@ -139,8 +153,7 @@ func (mr *mlReader) readTagChildren() error {
 				return err
 			}
 		default:
-			mr.index--
-			mr.reader.UnreadRune()
+			mr.unreadRune()
 			if err = mr.readTextNode(); err != nil {
 				return err
 			}
@@ -151,27 +164,26 @@ func (mr *mlReader) readTagChildren() error {
 // readBracedContent checks if the opening is for a tag node, raw node,
 // or comment and starts the reading of it.
 func (mr *mlReader) readBracedContent() error {
+	start := mr.current
 	_, rc, err := mr.readRune()
 	switch {
 	case err != nil:
 		return err
 	case rc == rcEOF:
-		return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a tag or raw node")
+		return mr.errorf("unexpected end of file while reading a tag or raw node")
 	case rc == rcTag:
-		mr.index--
-		mr.reader.UnreadRune()
-		return mr.readTagNode()
+		mr.unreadRune()
+		return mr.readTagNode(start)
 	case rc == rcExclamation:
-		return mr.readRawNode()
+		return mr.readRawNode(start)
 	case rc == rcHash:
-		return mr.readCommentNode()
+		return mr.readCommentNode(start)
 	}
-	msg := fmt.Sprintf("invalid character after opening at index %d", mr.index)
-	return errors.New(ErrReader, errorMessages, msg)
+	return mr.errorf("invalid character after opening")
 }
 
-// readRawNode reads a raw node.
-func (mr *mlReader) readRawNode() error {
+// readRawNode reads a raw node starting at the position.
+func (mr *mlReader) readRawNode(start position) error {
 	var buf bytes.Buffer
 	for {
 		r, rc, err := mr.readRune()
@@ -179,16 +191,16 @@ func (mr *mlReader) readRawNode() error {
 		case err != nil:
 			return err
 		case rc == rcEOF:
-			return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a raw node")
+			return mr.errorf("unexpected end of file while reading a raw node")
 		case rc == rcExclamation:
 			r, rc, err = mr.readRune()
 			switch {
 			case err != nil:
 				return err
 			case rc == rcEOF:
-				return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a raw node")
+				return mr.errorf("unexpected end of file while reading a raw node")
 			case rc == rcClose:
-				return mr.builder.RawNode(buf.String())
+				return mr.annotate(mr.builder.RawNode(buf.String()), start)
 			}
 			buf.WriteRune(chExclamation)
 			buf.WriteRune(r)
@@ -198,8 +210,8 @@ func (mr *mlReader) readRawNode() error {
 	}
 }
 
-// readCommentNode reads a raw node.
-func (mr *mlReader) readCommentNode() error {
+// readCommentNode reads a comment node starting at the position.
+func (mr *mlReader) readCommentNode(start position) error {
 	var buf bytes.Buffer
 	for {
 		r, rc, err := mr.readRune()
@@ -207,16 +219,16 @@ func (mr *mlReader) readCommentNode() error {
 		case err != nil:
 			return err
 		case rc == rcEOF:
-			return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a comment node")
+			return mr.errorf("unexpected end of file while reading a comment node")
 		case rc == rcHash:
 			r, rc, err = mr.readRune()
 			switch {
 			case err != nil:
 				return err
 			case rc == rcEOF:
-				return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a comment node")
+				return mr.errorf("unexpected end of file while reading a comment node")
 			case rc == rcClose:
-				return mr.builder.CommentNode(buf.String())
+				return mr.annotate(mr.builder.CommentNode(buf.String()), start)
 			}
 			buf.WriteRune(chHash)
 			buf.WriteRune(r)
@@ -229,29 +241,28 @@ func (mr *mlReader) readCommentNode() error {
 // readTextNode reads a text node.
 func (mr *mlReader) readTextNode() error {
 	var buf bytes.Buffer
+	start := mr.next
 	for {
 		r, rc, err := mr.readRune()
 		switch {
 		case err != nil:
 			return err
 		case rc == rcEOF:
-			return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a text node")
+			return mr.errorf("unexpected end of file while reading a text node")
 		case rc == rcOpen || rc == rcClose:
-			mr.index--
-			mr.reader.UnreadRune()
-			return mr.builder.TextNode(buf.String())
+			mr.unreadRune()
+			return mr.annotate(mr.builder.TextNode(buf.String()), start)
 		case rc == rcEscape:
 			r, rc, err = mr.readRune()
 			switch {
 			case err != nil:
 				return err
 			case rc == rcEOF:
-				return errors.New(ErrReader, errorMessages, "unexpected end of file while reading a text node")
+				return mr.errorf("unexpected end of file while reading a text node")
 			case rc == rcOpen || rc == rcClose || rc == rcEscape:
 				buf.WriteRune(r)
 			default:
-				msg := fmt.Sprintf("invalid character after escaping at index %d", mr.index)
-				return errors.New(ErrReader, errorMessages, msg)
+				return mr.errorf("invalid character after escaping")
 			}
 		default:
 			buf.WriteRune(r)
@@ -259,14 +270,22 @@ func (mr *mlReader) readTextNode() error {
 	}
 }
 
-// Reads one rune of the reader.
+// Reads one rune of the reader and keeps track of its position.
 func (mr *mlReader) readRune() (r rune, rc int, err error) {
 	var size int
-	mr.index++
 	r, size, err = mr.reader.ReadRune()
+	if err == io.EOF {
+		return 0, rcEOF, nil
+	}
 	if err != nil {
 		return 0, 0, err
 	}
+	mr.current = mr.next
+	if r == '\n' {
+		mr.next = position{mr.next.line + 1, 1}
+	} else {
+		mr.next.column++
+	}
 	switch {
 	case size == 0:
 		rc = rcEOF
@@ -296,4 +315,26 @@ func (mr *mlReader) readRune() (r rune, rc int, err error) {
 	return
 }
 
+// unreadRune unreads the last read rune, it has to be
+// read again as next one.
+func (mr *mlReader) unreadRune() {
+	mr.reader.UnreadRune()
+	mr.next = mr.current
+}
+
+// errorf creates a reader error at the position of
+// the last read rune.
+func (mr *mlReader) errorf(msg string) error {
+	return errors.New(ErrReader, errorMessages, fmt.Sprintf("%s at %v", msg, mr.current))
+}
+
+// annotate annotates an error of the builder with the
+// position of the node it has been building.
+func (mr *mlReader) annotate(err error, pos position) error {
+	if err == nil {
+		return nil
+	}
+	return errors.Annotate(err, ErrReader, errorMessages, fmt.Sprintf("node at %v", pos))
+}
+
 // EOF
@@ -120,7 +120,26 @@ func TestNegativeNodeReading(t *testing.T) {
 	text := "{Foo {bar:1 Yadda {test} {} 1} {bar:2 Yadda 2}}"
 	builder := sml.NewNodeBuilder()
 	err := sml.ReadSML(strings.NewReader(text), builder)
-	assert.ErrorMatch(err, `.* cannot read SML document: invalid character after opening at index .*`)
+	assert.ErrorMatch(err, `.* cannot read SML document: invalid character after opening at line 1, column 27`)
+}
+
+// TestErrorPositions checks the line and column of reading errors.
+func TestErrorPositions(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"{foo\n\t{bar 1}\n\t{b@z 2}\n}", `.* invalid tag character at line 3, column 4`},
+		{"{foo\n  {bar a ^x}}", `.* invalid character after escaping at line 2, column 11`},
+		{"{foo\n  {bar 1}\n", `.* unexpected end of file while reading a text node at line 2, column 10`},
+		{"{foo {bar 1}\n  {bar 2}}", `.* cannot read SML document: node at line 2, column 8: .* node has multiple values`},
+	}
+	for _, test := range tests {
+		builder := sml.NewKeyStringValueTreeBuilder()
+		err := sml.ReadSML(strings.NewReader(test.text), builder)
+		assert.ErrorMatch(err, test.err, test.text)
+	}
 }
 
 // TestPositiveTreeReading checks the successful reading of trees.
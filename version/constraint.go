@@ -0,0 +1,163 @@
+// Tideland Go Library - Version - Constraint
+//
+// Copyright (C) 2016 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package version
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tideland/golib/errors"
+)
+
+//--------------------
+// CONSTRAINT
+//--------------------
+
+// Constraint describes the requirements a version has to
+// satisfy, like ">=2.0.0 <3.0.0".
+type Constraint interface {
+	fmt.Stringer
+
+	// Matches returns true if the passed version satisfies
+	// the constraint.
+	Matches(v Version) bool
+}
+
+// operators maps the comparison operators to the precedences
+// of a version compared to the operand they accept. The longer
+// operators have to be checked first.
+var operators = []struct {
+	op          string
+	precedences []Precedence
+}{
+	{">=", []Precedence{Newer, Equal}},
+	{"<=", []Precedence{Older, Equal}},
+	{"!=", []Precedence{Newer, Older}},
+	{">", []Precedence{Newer}},
+	{"<", []Precedence{Older}},
+	{"=", []Precedence{Equal}},
+}
+
+// comparison contains the precedences accepted by an
+// operator and its version operand.
+type comparison struct {
+	precedences []Precedence
+	operand     Version
+}
+
+// matches checks the version against the comparison.
+func (c *comparison) matches(v Version) bool {
+	precedence, _ := v.Compare(c.operand)
+	for _, p := range c.precedences {
+		if p == precedence {
+			return true
+		}
+	}
+	return false
+}
+
+// constraint implements the Constraint interface. It's a list
+// of alternative ranges, each a list of comparisons.
+type constraint struct {
+	expr   string
+	ranges [][]*comparison
+}
+
+// ParseConstraint parses a constraint expression. It contains
+// comparisons of an operator out of =, !=, >, >=, <, and <= and
+// a version like ">=1.2.0", optionally separated by whitespace.
+// A missing operator means =. Comparisons separated by whitespace
+// or commas form a range, all of them have to match. Alternative
+// ranges are separated by "||".
+func ParseConstraint(expr string) (Constraint, error) {
+	c := &constraint{
+		expr: strings.TrimSpace(expr),
+	}
+	for _, rangestr := range strings.Split(expr, "||") {
+		fields := strings.FieldsFunc(rangestr, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 {
+			return nil, errors.New(ErrIllegalConstraintFormat, errorMessages, expr, "empty range")
+		}
+		cmps := []*comparison{}
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if isOperator(field) && i+1 < len(fields) {
+				// Operator separated from its version.
+				i++
+				field += fields[i]
+			}
+			cmp, err := parseComparison(field)
+			if err != nil {
+				return nil, errors.Annotate(err, ErrIllegalConstraintFormat, errorMessages, expr, field)
+			}
+			cmps = append(cmps, cmp)
+		}
+		c.ranges = append(c.ranges, cmps)
+	}
+	return c, nil
+}
+
+// Matches implements the Constraint interface.
+func (c *constraint) Matches(v Version) bool {
+	for _, cmps := range c.ranges {
+		matches := true
+		for _, cmp := range cmps {
+			if !cmp.matches(v) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements the fmt.Stringer interface.
+func (c *constraint) String() string {
+	return c.expr
+}
+
+// isOperator checks if the field only contains an operator.
+func isOperator(field string) bool {
+	for _, operator := range operators {
+		if field == operator.op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseComparison parses one operator and its version.
+func parseComparison(field string) (*comparison, error) {
+	cmp := &comparison{
+		precedences: []Precedence{Equal},
+	}
+	for _, operator := range operators {
+		if strings.HasPrefix(field, operator.op) {
+			cmp.precedences = operator.precedences
+			field = field[len(operator.op):]
+			break
+		}
+	}
+	operand, err := Parse(field)
+	if err != nil {
+		return nil, err
+	}
+	cmp.operand = operand
+	return cmp, nil
+}
+
+// EOF
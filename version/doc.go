@@ -13,7 +13,18 @@
 // Version instances can be created via New() with explicit passed
 // field values or via Parse() and a passed sting. Beside accessing
 // the individual fields two versions can be compared with Compare()
-// and Less().
+// and Less(). The precedence follows semantic versioning, e.g. a
+// pre-release is older than its release. A list of versions can be
+// sorted as Versions, Newest() returns the newest of them.
+//
+// Requirements can be expressed as constraints like ">=2.0.0 <3.0.0"
+// parsed with ParseConstraint(). Their Matches() method checks if a
+// version satisfies them.
+//
+//    c, err := version.ParseConstraint(">=2.0.0 <3.0.0 || >=3.1.0")
+//    if c.Matches(myVersion) {
+//        ...
+//    }
 package version
 
 // EOF
@@ -21,10 +21,12 @@ import (
 
 const (
 	ErrIllegalVersionFormat = iota + 1
+	ErrIllegalConstraintFormat
 )
 
 var errorMessages = errors.Messages{
-	ErrIllegalVersionFormat: "illegal version format: %s",
+	ErrIllegalVersionFormat:    "illegal version format: %s",
+	ErrIllegalConstraintFormat: "illegal version constraint %q: %s",
 }
 
 // EOF
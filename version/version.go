@@ -68,7 +68,10 @@ type Version interface {
 	Metadata() string
 
 	// Compare compares this version to the passed one. The result
-	// is from the perspective of this one.
+	// is from the perspective of this one. The precedence follows
+	// semantic versioning, so a pre-release is older than its release
+	// and metadata is ignored. As Newer, Equal, and Older are 1, 0,
+	// and -1 it can be used like other comparison results.
 	Compare(cv Version) (Precedence, Level)
 
 	// Less returns true if this version is less than the passed one.
@@ -118,7 +121,11 @@ func New(major, minor, patch int, prmds ...string) Version {
 	return v
 }
 
-// Parse retrieves a version out of a string.
+// Parse retrieves a version out of a string in the format
+// MAJOR.MINOR.PATCH with optional pre-release and metadata like
+// "1.2.3-beta.1+build.42". Missing minor and patch numbers are
+// set to zero. Malformed strings return an error describing
+// the problem.
 func Parse(vsnstr string) (Version, error) {
 	// Split version, pre-release, and metadata.
 	npmstrs, err := splitVersionString(vsnstr)
@@ -132,11 +139,19 @@ func Parse(vsnstr string) (Version, error) {
 	}
 	prmds := []string{}
 	if npmstrs[1] != "" {
-		prmds = strings.Split(npmstrs[1], ".")
+		prs, err := parseIDString("pre-release", npmstrs[1])
+		if err != nil {
+			return nil, err
+		}
+		prmds = prs
 	}
 	if npmstrs[2] != "" {
+		mds, err := parseIDString("metadata", npmstrs[2])
+		if err != nil {
+			return nil, err
+		}
 		prmds = append(prmds, Metadata)
-		prmds = append(prmds, strings.Split(npmstrs[2], ".")...)
+		prmds = append(prmds, mds...)
 	}
 	// Done.
 	return New(nums[0], nums[1], nums[2], prmds...), nil
@@ -193,6 +208,13 @@ func (v *vsn) Compare(cv Version) (Precedence, Level) {
 	}
 	vlen := len(v.preRelease)
 	cvlen := len(cvpr)
+	// A version without pre-release is newer than one with.
+	switch {
+	case vlen == 0 && cvlen > 0:
+		return Newer, PreRelease
+	case vlen > 0 && cvlen == 0:
+		return Older, PreRelease
+	}
 	count := vlen
 	if cvlen < vlen {
 		count = cvlen
@@ -210,6 +232,13 @@ func (v *vsn) Compare(cv Version) (Precedence, Level) {
 			}
 			continue
 		}
+		// Numerical identifiers are older than alphanumerical ones.
+		switch {
+		case verr == nil:
+			return Older, PreRelease
+		case cverr == nil:
+			return Newer, PreRelease
+		}
 		// Alphanumerical comparison.
 		switch {
 		case v.preRelease[i] < cvpr[i]:
@@ -219,12 +248,12 @@ func (v *vsn) Compare(cv Version) (Precedence, Level) {
 		}
 	}
 	// Still no clean result, so the shorter
-	// pre-relese is older.
+	// pre-release is older.
 	switch {
 	case vlen < cvlen:
-		return Newer, PreRelease
-	case vlen > cvlen:
 		return Older, PreRelease
+	case vlen > cvlen:
+		return Newer, PreRelease
 	}
 	// Last but not least: we are equal.
 	return Equal, All
@@ -248,6 +277,41 @@ func (v *vsn) String() string {
 	return vs
 }
 
+//--------------------
+// VERSIONS
+//--------------------
+
+// Versions is a list of versions implementing sort.Interface
+// to sort them from the oldest to the newest.
+type Versions []Version
+
+// Len implements the sort.Interface.
+func (vs Versions) Len() int {
+	return len(vs)
+}
+
+// Less implements the sort.Interface.
+func (vs Versions) Less(i, j int) bool {
+	return vs[i].Less(vs[j])
+}
+
+// Swap implements the sort.Interface.
+func (vs Versions) Swap(i, j int) {
+	vs[i], vs[j] = vs[j], vs[i]
+}
+
+// Newest returns the newest of the versions or nil
+// if the list is empty.
+func (vs Versions) Newest() Version {
+	var newest Version
+	for _, v := range vs {
+		if newest == nil || newest.Less(v) {
+			newest = v
+		}
+	}
+	return newest
+}
+
 //--------------------
 // TOOLS
 //--------------------
@@ -291,47 +355,91 @@ func validID(id string, numeric bool) string {
 // splitVersionString seperates the version string into numbers,
 // pre-release, and metadata strings.
 func splitVersionString(vsnstr string) ([]string, error) {
+	npmstrs := []string{"", "", ""}
 	npXm := strings.SplitN(vsnstr, Metadata, 2)
-	switch len(npXm) {
-	case 1:
-		nXp := strings.SplitN(npXm[0], "-", 2)
-		switch len(nXp) {
-		case 1:
-			return []string{nXp[0], "", ""}, nil
-		case 2:
-			return []string{nXp[0], nXp[1], ""}, nil
+	if len(npXm) == 2 {
+		if npXm[1] == "" {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "empty pre-release or metadata")
 		}
-	case 2:
-		nXp := strings.SplitN(npXm[0], "-", 2)
-		switch len(nXp) {
-		case 1:
-			return []string{nXp[0], "", npXm[1]}, nil
-		case 2:
-			return []string{nXp[0], nXp[1], npXm[1]}, nil
+		npmstrs[2] = npXm[1]
+	}
+	nXp := strings.SplitN(npXm[0], "-", 2)
+	if len(nXp) == 2 {
+		if nXp[1] == "" {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "empty pre-release or metadata")
 		}
+		npmstrs[1] = nXp[1]
 	}
-	return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "wrong parts")
+	npmstrs[0] = nXp[0]
+	return npmstrs, nil
 }
 
 // parseNumberString retrieves major, minor, and patch number
 // of the passed string.
 func parseNumberString(nstr string) ([]int, error) {
+	if nstr == "" {
+		return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "missing version number")
+	}
 	nstrs := strings.Split(nstr, ".")
-	if len(nstrs) < 1 || len(nstrs) > 3 {
-		return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "wrong number parts")
+	if len(nstrs) > 3 {
+		return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "more than three version numbers")
 	}
+	levels := []Level{Major, Minor, Patch}
 	vsn := []int{1, 0, 0}
 	for i, nstr := range nstrs {
+		if nstr == "" {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("empty %s number", levels[i]))
+		}
 		num, err := strconv.Atoi(nstr)
 		if err != nil {
-			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, err.Error())
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("%s number %q is not numeric", levels[i], nstr))
 		}
 		if num < 0 {
-			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, "negative version number")
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("%s number %q is negative", levels[i], nstr))
+		}
+		if hasLeadingZero(nstr) {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("%s number %q has a leading zero", levels[i], nstr))
 		}
 		vsn[i] = num
 	}
 	return vsn, nil
 }
 
+// parseIDString splits the dot separated identifiers of a
+// pre-release or metadata string and checks if they are valid.
+// Numeric identifiers with a leading zero are only allowed for
+// metadata.
+func parseIDString(kind, idstr string) ([]string, error) {
+	ids := strings.Split(idstr, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("empty %s identifier", kind))
+		}
+		for _, r := range id {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("%s identifier %q contains illegal character %q", kind, id, r))
+			}
+		}
+		if kind != "metadata" && isNumericID(id) && hasLeadingZero(id) {
+			return nil, errors.New(ErrIllegalVersionFormat, errorMessages, fmt.Sprintf("%s identifier %q has a leading zero", kind, id))
+		}
+	}
+	return ids, nil
+}
+
+// isNumericID checks if an identifier only contains digits.
+func isNumericID(id string) bool {
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLeadingZero checks if a number has a leading zero.
+func hasLeadingZero(nstr string) bool {
+	return len(nstr) > 1 && nstr[0] == '0'
+}
+
 // EOF
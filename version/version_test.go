@@ -12,6 +12,7 @@ package version_test
 //--------------------
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/tideland/golib/audit"
@@ -184,27 +185,68 @@ func TestParse(t *testing.T) {
 			patch:      3,
 			preRelease: "ALPHA",
 			metadata:   "007.a",
+		}, {
+			id:         "1.0.0-rc.1+build.5",
+			major:      1,
+			minor:      0,
+			patch:      0,
+			preRelease: "rc.1",
+			metadata:   "build.5",
+		}, {
+			id:         "2.1.0+exp.sha.5114f85",
+			major:      2,
+			minor:      1,
+			patch:      0,
+			preRelease: "",
+			metadata:   "exp.sha.5114f85",
 		}, {
 			id:  "",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: missing version number`,
 		}, {
 			id:  "a",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: major number "a" is not numeric`,
 		}, {
 			id:  "1.a",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: minor number "a" is not numeric`,
 		}, {
 			id:  "1,1",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: major number "1,1" is not numeric`,
 		}, {
 			id:  "-1",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: missing version number`,
 		}, {
 			id:  "1.-1",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: empty minor number`,
 		}, {
 			id:  "+",
-			err: `.* illegal version format: .*ParseInt.*`,
+			err: `.* illegal version format: empty pre-release or metadata`,
+		}, {
+			id:  "1.2.3.4",
+			err: `.* illegal version format: more than three version numbers`,
+		}, {
+			id:  "1.2.3-",
+			err: `.* illegal version format: empty pre-release or metadata`,
+		}, {
+			id:  "1.2.3-alpha..1",
+			err: `.* illegal version format: empty pre-release identifier`,
+		}, {
+			id:  "1.2.3-alpha+build_1",
+			err: `.* illegal version format: metadata identifier "build_1" contains illegal character '_'`,
+		}, {
+			id:  "01.2.3",
+			err: `.* illegal version format: major number "01" has a leading zero`,
+		}, {
+			id:  "1.2.03",
+			err: `.* illegal version format: patch number "03" has a leading zero`,
+		}, {
+			id:  "1.2.3-01",
+			err: `.* illegal version format: pre-release identifier "01" has a leading zero`,
+		}, {
+			id:  "1.2.3-+m",
+			err: `.* illegal version format: empty pre-release or metadata`,
+		}, {
+			id:  "1.2.3-alpha+",
+			err: `.* illegal version format: empty pre-release or metadata`,
 		},
 	}
 	// Perform tests.
@@ -271,7 +313,7 @@ func TestCompare(t *testing.T) {
 		}, {
 			vsnA:       version.New(1, 2, 3, "alpha", "1"),
 			vsnB:       version.New(1, 2, 3, "alpha"),
-			precedence: version.Older,
+			precedence: version.Newer,
 			level:      version.PreRelease,
 		}, {
 			vsnA:       version.New(1, 2, 3, "alpha", "1"),
@@ -311,7 +353,7 @@ func TestCompare(t *testing.T) {
 		}, {
 			vsnA:       version.New(1, 2, 3, "alpha", "2"),
 			vsnB:       version.New(1, 2, 3, "alpha", "1b"),
-			precedence: version.Newer,
+			precedence: version.Older,
 			level:      version.PreRelease,
 		},
 	}
@@ -359,7 +401,7 @@ func TestLess(t *testing.T) {
 		}, {
 			vsnA: version.New(1, 2, 3, "alpha", "1"),
 			vsnB: version.New(1, 2, 3, "alpha"),
-			less: true,
+			less: false,
 		}, {
 			vsnA: version.New(1, 2, 3, "alpha", "1"),
 			vsnB: version.New(1, 2, 3, "alpha", "2"),
@@ -391,7 +433,7 @@ func TestLess(t *testing.T) {
 		}, {
 			vsnA: version.New(1, 2, 3, "alpha", "2"),
 			vsnB: version.New(1, 2, 3, "alpha", "1b"),
-			less: false,
+			less: true,
 		},
 	}
 	// Perform tests.
@@ -401,4 +443,99 @@ func TestLess(t *testing.T) {
 	}
 }
 
+// TestSort tests the sorting of versions by their precedence.
+func TestSort(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	// Order as in the semantic versioning specification.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	vs := version.Versions{}
+	for _, i := range []int{7, 3, 10, 0, 5, 9, 1, 6, 4, 8, 2} {
+		v, err := version.Parse(ordered[i])
+		assert.Nil(err)
+		vs = append(vs, v)
+	}
+	sort.Sort(vs)
+	for i, v := range vs {
+		assert.Equal(v.String(), ordered[i])
+	}
+	assert.Equal(vs.Newest().String(), "2.0.0")
+	assert.Nil(version.Versions{}.Newest())
+	// Pre-release lower than release, metadata ignored.
+	alpha := version.New(1, 0, 0, "alpha")
+	release := version.New(1, 0, 0)
+	build := version.New(1, 0, 0, version.Metadata, "build", "42")
+	assert.True(alpha.Less(release))
+	precedence, _ := release.Compare(alpha)
+	assert.Equal(precedence, version.Newer)
+	precedence, _ = release.Compare(build)
+	assert.True(precedence == version.Equal)
+	precedence, _ = alpha.Compare(release)
+	assert.Equal(int(precedence), -1)
+}
+
+// TestConstraint tests the matching of versions against
+// constraints.
+func TestConstraint(t *testing.T) {
+	assert := audit.NewTestingAssertion(t, true)
+	tests := []struct {
+		expr    string
+		vsn     string
+		matches bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.3+build.1", true},
+		{"=1.2.3", "1.2.4", false},
+		{"!=1.2.3", "1.2.4", true},
+		{"!=1.2.3", "1.2.3", false},
+		{">1.2.3", "1.2.4", true},
+		{">1.2.3", "1.2.3", false},
+		{">=1.2.3", "1.2.3", true},
+		{">=1.2.3", "1.2.2", false},
+		{"<1.2.3", "1.2.2", true},
+		{"<1.2.3", "1.2.3", false},
+		{"<=1.2.3", "1.2.3", true},
+		{"<=1.2.3", "1.3.0", false},
+		{">=2.0.0 <3.0.0", "2.5.1", true},
+		{">=2.0.0 <3.0.0", "3.0.0", false},
+		{">=2.0.0 <3.0.0", "1.9.9", false},
+		{">=2.0, <3", "2.0.0", true},
+		{">= 2.0.0, < 3.0.0", "2.0.0", true},
+		{"<1.0.0 || >=2.0.0", "0.9.0", true},
+		{"<1.0.0 || >=2.0.0", "2.1.0", true},
+		{"<1.0.0 || >=2.0.0", "1.5.0", false},
+		{">=1.0.0", "1.0.0-beta", false},
+		{"<1.0.0", "1.0.0-beta", true},
+		{">=1.0.0-alpha", "1.0.0-beta", true},
+		{">=1.0.0-beta", "1.0.0-alpha", false},
+		{">1.0.0-rc.2", "1.0.0-rc.10", true},
+		{"<1.0.0", "1.0.0-rc.1+build.5", true},
+	}
+	for i, test := range tests {
+		assert.Logf("constraint test #%d: %q matches %q -> %v", i, test.expr, test.vsn, test.matches)
+		c, err := version.ParseConstraint(test.expr)
+		assert.Nil(err)
+		v, err := version.Parse(test.vsn)
+		assert.Nil(err)
+		assert.Equal(c.Matches(v), test.matches)
+	}
+	// Illegal constraints.
+	for i, expr := range []string{"", ">=1.0.0 ||", ">=a.b", "~1.2.3", ">=1.0.0 <"} {
+		assert.Logf("illegal constraint test #%d: %q", i, expr)
+		_, err := version.ParseConstraint(expr)
+		assert.ErrorMatch(err, `.* illegal version constraint .*`)
+	}
+}
+
 // EOF